@@ -0,0 +1,153 @@
+package goparser
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultMatchCacheSize Match 默认缓存的表达式个数
+const DefaultMatchCacheSize = 1024
+
+// Match、MatchContext 使用的编译结果缓存
+var matchCache = NewProgramCache(DefaultMatchCacheSize)
+
+// SetMatchCacheSize 设置 Match、MatchContext 缓存的表达式个数，为0时关闭缓存，每次计算重新解析表达式
+func SetMatchCacheSize(n int) {
+	matchCache.Resize(n)
+}
+
+// MatchCacheStats 返回 Match、MatchContext 缓存的统计信息
+func MatchCacheStats() CacheStats {
+	return matchCache.Stats()
+}
+
+// CacheStats 编译结果缓存的统计信息
+type CacheStats struct {
+	Hits      int64 `json:"hits"`      // 命中次数
+	Misses    int64 `json:"misses"`    // 未命中次数
+	Evictions int64 `json:"evictions"` // 淘汰次数
+	Len       int   `json:"len"`       // 缓存的表达式个数
+	Size      int   `json:"size"`      // 最多缓存的表达式个数
+}
+
+// cacheEntry 缓存的编译结果，编译失败时记录错误
+type cacheEntry struct {
+	expr string
+	p    *Program
+	err  error
+}
+
+// ProgramCache 以表达式字符串为键的编译结果LRU缓存，可被多个协程并发使用
+type ProgramCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+
+	hits, misses, evictions int64
+}
+
+// NewProgramCache 创建缓存，size 为最多缓存的表达式个数，为0时不缓存
+func NewProgramCache(size int) *ProgramCache {
+	return &ProgramCache{size: size, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// Compile 返回缓存的编译结果，未命中时编译并缓存。编译失败的错误同样被缓存，避免重复解析；
+// 同一缓存应使用相同的编译选项，命中时不会按照 opts 重新校验
+func (c *ProgramCache) Compile(expr string, opts ...CompileOption) (*Program, error) {
+	if entry, ok := c.lookup(expr); ok {
+		return entry.p, entry.err
+	}
+	p, err := Compile(expr, opts...)
+	c.add(&cacheEntry{expr: expr, p: p, err: err})
+	return p, err
+}
+
+// Get 获取缓存的编译结果，编译失败的表达式返回false
+func (c *ProgramCache) Get(expr string) (*Program, bool) {
+	entry, ok := c.lookup(expr)
+	if !ok || entry.err != nil {
+		return nil, false
+	}
+	return entry.p, true
+}
+
+// Add 缓存编译结果，超出容量时淘汰最久未使用的表达式
+func (c *ProgramCache) Add(p *Program) {
+	c.add(&cacheEntry{expr: p.expr, p: p})
+}
+
+// lookup 查找缓存的编译结果并统计命中次数
+func (c *ProgramCache) lookup(expr string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[expr]; ok {
+		c.hits++
+		c.ll.MoveToFront(e)
+		return e.Value.(*cacheEntry), true
+	}
+	c.misses++
+	return nil, false
+}
+
+// add 缓存编译结果，已存在时替换
+func (c *ProgramCache) add(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+	if e, ok := c.items[entry.expr]; ok {
+		e.Value = entry
+		c.ll.MoveToFront(e)
+		return
+	}
+	c.items[entry.expr] = c.ll.PushFront(entry)
+	c.evict()
+}
+
+// Resize 调整最多缓存的表达式个数，为0时清空并关闭缓存
+func (c *ProgramCache) Resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.size = size
+	c.evict()
+}
+
+// Purge 清空缓存及统计信息
+func (c *ProgramCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	c.hits, c.misses, c.evictions = 0, 0, 0
+}
+
+// Stats 返回统计信息
+func (c *ProgramCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{Hits: c.hits, Misses: c.misses, Evictions: c.evictions, Len: c.ll.Len(), Size: c.size}
+}
+
+// enabled 是否开启缓存
+func (c *ProgramCache) enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size > 0
+}
+
+// evict 淘汰超出容量的表达式，调用方需持有锁
+func (c *ProgramCache) evict() {
+	for c.ll.Len() > c.size && c.ll.Len() > 0 {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).expr)
+		c.evictions++
+	}
+}
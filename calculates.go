@@ -1,12 +1,20 @@
 package goparser
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"go/ast"
 	"go/token"
 	"math"
 )
 
+// 整数运算错误定义
+var (
+	ErrDivisionByZero  = errors.New("integer divide by zero") // 除数为零
+	ErrIntegerOverflow = errors.New("integer overflow")       // 运算结果溢出
+)
+
 // 计算int类型表达式
 func calculateForInt(x, y interface{}, op token.Token) interface{} {
 	x, err := castType(x, TypeInt64)
@@ -46,26 +54,160 @@ func calculateForInt64(x, y interface{}, op token.Token) interface{} {
 		return xInt >= yInt
 	case token.LEQ:
 		return xInt <= yInt
+	case token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+		return arithmeticForInt64(xInt, yInt, op)
+	case token.AND: // &
+		return xInt & yInt
+	case token.OR: // |
+		return xInt | yInt
+	case token.XOR: // ^
+		return xInt ^ yInt
+	case token.AND_NOT: // &^
+		return xInt &^ yInt
+	case token.SHL, token.SHR:
+		// 与Go语义保持一致：位移量不能为负数
+		if yInt < 0 {
+			return fmt.Errorf("negative shift amount: %d", yInt)
+		}
+		if op == token.SHL {
+			return xInt << uint64(yInt)
+		}
+		return xInt >> uint64(yInt)
+	default:
+		return fmt.Errorf("unsupported binary operator: %s", op.String())
+	}
+}
+
+// arithmeticForInt64 带溢出及除零检查的int64四则运算
+func arithmeticForInt64(x, y int64, op token.Token) interface{} {
+	switch op {
 	case token.ADD:
-		return xInt + yInt
+		if (y > 0 && x > math.MaxInt64-y) || (y < 0 && x < math.MinInt64-y) {
+			return fmt.Errorf("%d + %d: %w", x, y, ErrIntegerOverflow)
+		}
+		return x + y
 	case token.SUB:
-		return xInt - yInt
+		if (y < 0 && x > math.MaxInt64+y) || (y > 0 && x < math.MinInt64+y) {
+			return fmt.Errorf("%d - %d: %w", x, y, ErrIntegerOverflow)
+		}
+		return x - y
 	case token.MUL:
-		return xInt * yInt
+		if x == 0 || y == 0 {
+			return int64(0)
+		}
+		r := x * y
+		if r/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+			return fmt.Errorf("%d * %d: %w", x, y, ErrIntegerOverflow)
+		}
+		return r
 	case token.QUO:
-		if yInt == 0 {
-			return 0
+		if y == 0 {
+			return fmt.Errorf("%d / %d: %w", x, y, ErrDivisionByZero)
 		}
-		return xInt / yInt
+		if x == math.MinInt64 && y == -1 {
+			return fmt.Errorf("%d / %d: %w", x, y, ErrIntegerOverflow)
+		}
+		return x / y
 	case token.REM:
-		val := math.Mod(float64(xInt), float64(yInt))
-		if math.IsNaN(val) {
-			return fmt.Errorf("remainder cannot be 0")
+		if y == 0 {
+			return fmt.Errorf("%d %% %d: %w", x, y, ErrDivisionByZero)
 		}
-		return int64(val)
-	default:
-		return fmt.Errorf("unsupported binary operator: %s", op.String())
+		return x % y
+	}
+	return fmt.Errorf("unsupported binary operator: %s", op.String())
+}
+
+// 计算int64类型一元表达式（-x、+x、^x）
+func calculateForUnaryInt64(x interface{}, op token.Token) interface{} {
+	x, err := castType(x, TypeInt64)
+	if err != nil {
+		return err
+	}
+	xInt, ok := x.(int64)
+	if !ok {
+		return fmt.Errorf("%v%v eval failed", op, x)
+	}
+
+	switch op {
+	case token.SUB:
+		if xInt == math.MinInt64 {
+			return fmt.Errorf("-(%d): %w", xInt, ErrIntegerOverflow)
+		}
+		return -xInt
+	case token.ADD:
+		return xInt
+	case token.XOR:
+		return ^xInt
 	}
+	return fmt.Errorf("unsupported unary operator: %s", op.String())
+}
+
+// 计算float64类型表达式
+func calculateForFloat64(x, y interface{}, op token.Token) interface{} {
+	xFloat, err := castToFloat64(x)
+	if err != nil {
+		return err
+	}
+	yFloat, err := castToFloat64(y)
+	if err != nil {
+		return err
+	}
+
+	// 计算逻辑
+	switch op {
+	case token.EQL:
+		return xFloat == yFloat
+	case token.NEQ:
+		return xFloat != yFloat
+	case token.GTR:
+		return xFloat > yFloat
+	case token.LSS:
+		return xFloat < yFloat
+	case token.GEQ:
+		return xFloat >= yFloat
+	case token.LEQ:
+		return xFloat <= yFloat
+	case token.ADD:
+		return xFloat + yFloat
+	case token.SUB:
+		return xFloat - yFloat
+	case token.MUL:
+		return xFloat * yFloat
+	case token.QUO:
+		if yFloat == 0 {
+			return fmt.Errorf("%v / %v: %w", xFloat, yFloat, ErrDivisionByZero)
+		}
+		return xFloat / yFloat
+	case token.REM:
+		// 与Go语义保持一致：浮点数不支持取模
+		return fmt.Errorf("%v %% %v: operator %% not defined on float64", xFloat, yFloat)
+	}
+	return fmt.Errorf("unsupported binary operator: %s", op.String())
+}
+
+// isFloat 是否为浮点数
+func isFloat(v interface{}) bool {
+	switch v.(type) {
+	case float32, float64:
+		return true
+	}
+	return false
+}
+
+// 计算float64类型一元表达式（-x、+x）
+func calculateForUnaryFloat64(x interface{}, op token.Token) interface{} {
+	xFloat, err := castToFloat64(x)
+	if err != nil {
+		return err
+	}
+
+	switch op {
+	case token.SUB:
+		return -xFloat
+	case token.ADD:
+		return xFloat
+	}
+	return fmt.Errorf("unsupported unary operator: %s", op.String())
 }
 
 // 计算string类型表达式
@@ -116,12 +258,20 @@ func calculateForBool(x, y interface{}, op token.Token) interface{} {
 	return fmt.Errorf("unsupported binary operator: %s", op.String())
 }
 
-// calculateForFunc 计算函数表达式
-func calculateForFunc(funcName string, args []ast.Expr, data map[string]interface{}) interface{} {
+// calculateForFunc 计算函数表达式，自定义函数中的panic会被转换为错误返回
+func calculateForFunc(ctx context.Context, funcName string, args []ast.Expr, data map[string]interface{}) (result interface{}) {
 	// 根据funcName分发逻辑
 	handler, ok := funcNameMap[funcName]
 	if !ok {
 		return fmt.Errorf("%+v func not support", funcName)
 	}
-	return handler(args, data)
+
+	return observeFunc(ctx, funcName, args, func() (result interface{}) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = &PanicError{Expr: funcName + "(...)", Value: r}
+			}
+		}()
+		return handler(ctx, args, data)
+	})
 }
@@ -0,0 +1,326 @@
+// goparser 规则表达式命令行工具，用于计算、校验、解释表达式及与JSON条件树相互转换
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	goparser "github.com/BeCrafter/go-parser"
+	"github.com/BeCrafter/go-parser/server"
+)
+
+const usage = `usage: goparser <command> [flags] [args]
+
+commands:
+  eval [-data file|-] [-json] EXPR           计算表达式
+  check [-data file|-] [-json] EXPR          校验表达式语法，指定数据时同时校验计算
+  fields [-tree file|-] [-json] [EXPR]       导出表达式或JSON条件树中的变量
+  tojson EXPR                                表达式转换为JSON条件树
+  fromjson [-json] [file|-]                  JSON条件树转换为表达式
+  explain [-data file|-] [-json] EXPR        解释计算过程
+  repl [-data file]                          交互式编写规则
+  serve [-addr :8080] [-timeout 1s]          启动HTTP规则计算服务
+
+数据及条件树为JSON，"-" 表示从标准输入读取
+`
+
+func main() {
+	goparser.RegisterStdlib()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// command 子命令的执行环境
+type command struct {
+	flags  *flag.FlagSet
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	json bool   // 以JSON格式输出
+	data string // 数据文件
+	tree string // 条件树文件
+
+	addr    string        // HTTP服务监听地址
+	maxBody int64         // HTTP请求体最大字节数
+	cache   int           // 编译结果缓存的表达式个数
+	timeout time.Duration // 单次计算的超时时间
+}
+
+// commands 子命令
+var commands = map[string]func(c *command) error{
+	"eval":     (*command).eval,
+	"check":    (*command).check,
+	"fields":   (*command).fields,
+	"tojson":   (*command).toJSON,
+	"fromjson": (*command).fromJSON,
+	"explain":  (*command).explain,
+	"repl":     (*command).repl,
+	"serve":    (*command).serve,
+}
+
+// run 执行命令并返回退出码
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "help" {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	fn, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "goparser: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	c := &command{flags: flag.NewFlagSet(args[0], flag.ContinueOnError), stdin: stdin, stdout: stdout, stderr: stderr}
+	c.flags.SetOutput(stderr)
+	switch args[0] {
+	case "eval", "check", "fields", "fromjson", "explain":
+		c.flags.BoolVar(&c.json, "json", false, "以JSON格式输出")
+	}
+	switch args[0] {
+	case "eval", "check", "explain", "repl":
+		c.flags.StringVar(&c.data, "data", "", "JSON数据文件，- 表示标准输入")
+	case "fields":
+		c.flags.StringVar(&c.tree, "tree", "", "JSON条件树文件，- 表示标准输入")
+	case "serve":
+		c.flags.StringVar(&c.addr, "addr", ":8080", "监听地址")
+		c.flags.Int64Var(&c.maxBody, "max-body", 1<<20, "请求体最大字节数")
+		c.flags.IntVar(&c.cache, "cache", 1000, "编译结果缓存的表达式个数")
+		c.flags.DurationVar(&c.timeout, "timeout", time.Second, "单次计算的超时时间，0 表示不限制")
+	}
+	if err := c.flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	if err := fn(c); err != nil {
+		switch {
+		case errors.Is(err, errInvalid), errors.Is(err, errFailed):
+			// 校验问题、计算错误已输出
+		case c.json:
+			_ = c.output(map[string]interface{}{"error": err.Error()}, "")
+		default:
+			fmt.Fprintf(stderr, "goparser %s: %v\n", args[0], err)
+		}
+		return 1
+	}
+	return 0
+}
+
+// eval 计算表达式并输出结果
+func (c *command) eval() error {
+	expr, err := c.expr()
+	if err != nil {
+		return err
+	}
+	data, err := c.readData()
+	if err != nil {
+		return err
+	}
+	v, err := goparser.EvalValue(expr, data)
+	if err != nil {
+		return err
+	}
+	return c.output(map[string]interface{}{"result": v}, fmt.Sprint(v))
+}
+
+// check 校验表达式，指定数据时同时计算以检查类型错误
+func (c *command) check() error {
+	expr, err := c.expr()
+	if err != nil {
+		return err
+	}
+
+	var issues []string
+	if err := goparser.Validate(expr); err != nil {
+		issues = goparser.ErrorIssues(err)
+	} else if _, err := goparser.Compile(expr); err != nil {
+		issues = goparser.ErrorIssues(err)
+	} else if c.data != "" {
+		data, err := c.readData()
+		if err != nil {
+			return err
+		}
+		if _, err := goparser.EvalValue(expr, data); err != nil {
+			issues = goparser.ErrorIssues(err)
+		}
+	}
+
+	text := "ok"
+	if len(issues) > 0 {
+		text = strings.Join(issues, "\n")
+	}
+	if err := c.output(map[string]interface{}{"valid": len(issues) == 0, "issues": issues}, text); err != nil {
+		return err
+	}
+	if len(issues) > 0 {
+		return errInvalid
+	}
+	return nil
+}
+
+// errInvalid 表达式校验未通过，问题已输出
+var errInvalid = errors.New("invalid expression")
+
+// errFailed 表达式计算出错，错误已随计算过程输出
+var errFailed = errors.New("evaluation failed")
+
+// fields 导出表达式或条件树中的变量
+func (c *command) fields() error {
+	var fields []string
+	var err error
+	if c.tree != "" {
+		tree, e := c.readTree(c.tree)
+		if e != nil {
+			return e
+		}
+		fields, err = goparser.ExportFields(tree)
+	} else {
+		expr, e := c.expr()
+		if e != nil {
+			return e
+		}
+		fields, err = goparser.ExportVars(expr)
+	}
+	if err != nil {
+		return err
+	}
+	return c.output(map[string]interface{}{"fields": fields}, strings.Join(fields, "\n"))
+}
+
+// toJSON 表达式转换为条件树
+func (c *command) toJSON() error {
+	expr, err := c.expr()
+	if err != nil {
+		return err
+	}
+	tree, err := goparser.ExpressionTree(expr)
+	if err != nil {
+		return err
+	}
+	c.json = true
+	return c.output(tree, "")
+}
+
+// fromJSON 条件树转换为表达式
+func (c *command) fromJSON() error {
+	file := "-"
+	if c.flags.NArg() > 0 {
+		file = c.flags.Arg(0)
+	}
+	tree, err := c.readTree(file)
+	if err != nil {
+		return err
+	}
+	expr, err := goparser.Expression(tree)
+	if err != nil {
+		return err
+	}
+	return c.output(map[string]interface{}{"expr": expr}, expr)
+}
+
+// explain 输出计算过程
+func (c *command) explain() error {
+	expr, err := c.expr()
+	if err != nil {
+		return err
+	}
+	data, err := c.readData()
+	if err != nil {
+		return err
+	}
+	node, err := goparser.Explain(expr, data)
+	if err != nil {
+		return err
+	}
+	if err := c.output(node, node.String()); err != nil {
+		return err
+	}
+	if node.Err != nil {
+		return errFailed
+	}
+	return nil
+}
+
+// serve 启动HTTP规则计算服务
+func (c *command) serve() error {
+	srv := server.New(server.MaxBodyBytes(c.maxBody), server.CacheSize(c.cache), server.Timeout(c.timeout))
+	fmt.Fprintf(c.stderr, "goparser serve: listening on %s\n", c.addr)
+	return http.ListenAndServe(c.addr, srv)
+}
+
+// expr 命令行参数中的表达式，多个参数以空格连接
+func (c *command) expr() (string, error) {
+	if c.flags.NArg() == 0 {
+		return "", errors.New("missing expression")
+	}
+	return strings.Join(c.flags.Args(), " "), nil
+}
+
+// readData 读取JSON数据，未指定时为空数据
+func (c *command) readData() (map[string]interface{}, error) {
+	if c.data == "" {
+		return map[string]interface{}{}, nil
+	}
+	return c.loadData(c.data)
+}
+
+// loadData 从文件或标准输入读取JSON数据，数据须为对象，null 视为空数据
+func (c *command) loadData(file string) (map[string]interface{}, error) {
+	var data map[string]interface{}
+	if err := c.readJSON(file, &data); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	for k, v := range data {
+		data[k] = goparser.NormalizeJSON(v)
+	}
+	return data, nil
+}
+
+// readTree 读取JSON条件树
+func (c *command) readTree(file string) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	if err := c.readJSON(file, &tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// readJSON 从文件或标准输入读取JSON，数字解析为 json.Number
+func (c *command) readJSON(file string, v interface{}) error {
+	var r io.Reader = c.stdin
+	if file != "-" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("decode %s: %w", file, err)
+	}
+	return nil
+}
+
+// output 按照输出格式输出结果，text 为文本格式的内容
+func (c *command) output(v interface{}, text string) error {
+	if !c.json {
+		_, err := fmt.Fprintln(c.stdout, text)
+		return err
+	}
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+	}{
+		{name: "eval", args: []string{"eval", "-data", "-", "price * 2 + 1"}, stdin: `{"price": 100}`, stdout: "201\n"},
+		{name: "eval_json", args: []string{"eval", "-json", "-data", "-", `age >= 18 && city == "bj"`}, stdin: `{"age": 20, "city": "bj"}`, stdout: "{\n  \"result\": true\n}\n"},
+		{name: "eval_float", args: []string{"eval", "-data", "-", "2 * rate"}, stdin: `{"rate": 0.25}`, stdout: "0.5\n"},
+		{name: "eval_error", args: []string{"eval", "-json", "a +"}, code: 1, stdout: "{\n  \"error\": \"1:4: expected operand, found 'EOF'\"\n}\n"},
+		{name: "check_ok", args: []string{"check", "a > 1 && in_array(b, []int{1, 2})"}, stdout: "ok\n"},
+		{name: "check_syntax", args: []string{"check", `a[0] == 1 && x.(int) > 2`}, code: 1, stdout: "1:1: index expression\n1:14: type assertion\n"},
+		{name: "check_type", args: []string{"check", "-data", "-", "a > 1"}, stdin: `{"a": true}`, code: 1},
+		{name: "fields", args: []string{"fields", "max(a, b) > c && a < 10"}, stdout: "a\nb\nc\n"},
+		{name: "fields_tree", args: []string{"fields", "-json", "-tree", "-"}, stdin: `{"connector": "AND", "children": [{"op": "GE", "field": "age", "value": 18}]}`, stdout: "{\n  \"fields\": [\n    \"age\"\n  ]\n}\n"},
+		{name: "fromjson", args: []string{"fromjson"}, stdin: `{"connector": "NOT", "children": [{"connector": "AND", "children": [{"op": "GE", "field": "age", "value": 18}]}]}`, stdout: "!(age >= 18)\n"},
+		{name: "explain", args: []string{"explain", "-data", "-", "age >= 18 && vip"}, stdin: `{"age": 16, "vip": true}`, stdout: "age >= 18 && vip → false\n  age >= 18 → false (age = 16)\n  vip [short-circuited]\n"},
+		{name: "explain_error", args: []string{"explain", "-data", "-", "a / b > 1"}, stdin: `{"a": 1, "b": 0}`, code: 1, stdout: "a / b > 1 → error: 1 / 0: integer divide by zero\n  a / b → error: 1 / 0: integer divide by zero (a = 1, b = 0)\n"},
+		{name: "explain_error_json", args: []string{"explain", "-json", "-data", "-", "a / b > 1"}, stdin: `{"a": 1, "b": 0}`, code: 1},
+		{name: "repl_json", args: []string{"repl", "-json"}, code: 2},
+		{name: "unknown", args: []string{"run"}, code: 2},
+		{name: "missing_expr", args: []string{"eval"}, code: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if code != tt.code {
+				t.Errorf("run %v code=%d, want %d, stderr=%s", tt.args, code, tt.code, stderr.String())
+			}
+			if tt.stdout != "" && stdout.String() != tt.stdout {
+				t.Errorf("run %v stdout=%q, want %q", tt.args, stdout.String(), tt.stdout)
+			}
+		})
+	}
+}
+
+func TestRunRoundTrip(t *testing.T) {
+	expr := `age >= 18 && (name == "tom" || !(level > -3)) && vip == true && path != "c:\\a\"b"`
+
+	var tree, out, stderr bytes.Buffer
+	if code := run([]string{"tojson", expr}, nil, &tree, &stderr); code != 0 {
+		t.Fatalf("tojson code=%d, stderr=%s", code, stderr.String())
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(tree.Bytes(), &v); err != nil || v["connector"] != "AND" {
+		t.Fatalf("tojson output=%s, err=%v", tree.String(), err)
+	}
+	if code := run([]string{"fromjson", "-json"}, &tree, &out, &stderr); code != 0 {
+		t.Fatalf("fromjson code=%d, stderr=%s", code, stderr.String())
+	}
+
+	// 转换后的表达式与原表达式的计算结果一致
+	var got map[string]string
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("fromjson output=%s, err=%v", out.String(), err)
+	}
+	for _, data := range []string{`{"age": 20, "name": "tom", "level": 0, "vip": true, "path": ""}`, `{"age": 20, "name": "x", "level": 0, "vip": true, "path": ""}`, `{"age": 20, "name": "x", "level": -5, "vip": true, "path": "c:\\a\"b"}`} {
+		var want, res bytes.Buffer
+		run([]string{"eval", "-data", "-", expr}, strings.NewReader(data), &want, &stderr)
+		run([]string{"eval", "-data", "-", got["expr"]}, strings.NewReader(data), &res, &stderr)
+		if want.Len() == 0 || !reflect.DeepEqual(want.String(), res.String()) {
+			t.Errorf("round trip %s with %s got=%s, want %s", got["expr"], data, res.String(), want.String())
+		}
+	}
+}
+
+func TestRunREPL(t *testing.T) {
+	input := strings.Join([]string{
+		`age >= 18 && vip`,
+		`:set age 20`,
+		`:set city bei jing`,
+		`!1`,
+		`!vip`,
+		`city == "bei jing"`,
+		`:unset vip`,
+		`:funcs`,
+		`:history`,
+		`!9`,
+		`:bogus`,
+		`:data`,
+		`:quit`,
+		`never evaluated`,
+	}, "\n")
+
+	file := filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(file, []byte(`{"age": 16, "vip": true}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"repl", "-data", file}, strings.NewReader(input), &stdout, &stderr); code != 0 {
+		t.Fatalf("repl code=%d, stderr=%s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"= false\nage >= 18 && vip → false\n  age >= 18 → false (age = 16)\n  vip [short-circuited]\n",
+		"age >= 18 && vip\n= true\nage >= 18 && vip → true (vip = true)\n  age >= 18 → true (age = 20)\n",
+		"= false\n!vip → false (vip = true)\n",
+		`city == "bei jing" → true (city = "bei jing")`,
+		"in_array\n",
+		"1  age >= 18 && vip\n2  age >= 18 && vip\n3  !vip\n4  city == \"bei jing\"\n",
+		"error: no history entry 9",
+		"error: unknown command :bogus",
+		"{\n  \"age\": 20,\n  \"city\": \"bei jing\"\n}",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("repl output missing %q\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "never evaluated") {
+		t.Errorf("repl evaluated input after :quit")
+	}
+}
+
+func TestRunREPLNullData(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "null.json")
+	if err := os.WriteFile(file, []byte(`null`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// null 数据视为空数据，加载后依然可以设置变量
+	input := strings.Join([]string{":load " + file, ":set a 1", "a == 1", ":load " + file + ".missing", ":data"}, "\n")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"repl", "-data", file}, strings.NewReader(input), &stdout, &stderr); code != 0 {
+		t.Fatalf("repl code=%d, stderr=%s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"= true\n", "error: open ", "{\n  \"a\": 1\n}"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("repl output missing %q\n%s", want, out)
+		}
+	}
+}
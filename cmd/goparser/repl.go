@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	goparser "github.com/BeCrafter/go-parser"
+)
+
+const replHelp = `输入表达式计算并解释计算过程，或输入命令：
+  :set field value   设置变量，value 按JSON解析，解析失败时作为字符串
+  :unset field       删除变量
+  :load file         从JSON文件加载数据，替换当前数据
+  :data              查看当前数据
+  :funcs             列出已注册的函数
+  :history           查看历史表达式，!n 重新计算第 n 条
+  :help              查看帮助
+  :quit              退出`
+
+// repl 交互式编写规则：读取表达式，输出计算结果及计算过程
+func (c *command) repl() error {
+	data, err := c.readData()
+	if err != nil {
+		return err
+	}
+	r := &replSession{command: c, data: data}
+
+	fmt.Fprintln(c.stdout, "goparser repl, :help 查看帮助")
+	scanner := bufio.NewScanner(c.stdin)
+	for {
+		fmt.Fprint(c.stdout, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(c.stdout)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == ":quit" || line == ":q" {
+			return nil
+		}
+		r.exec(line)
+	}
+}
+
+// replSession 交互式会话状态
+type replSession struct {
+	*command
+	data    map[string]interface{}
+	history []string
+}
+
+// exec 执行一行输入
+func (r *replSession) exec(line string) {
+	switch {
+	case line == "":
+	case isHistoryRef(line):
+		// !n 引用历史表达式，其余 ! 开头的输入按表达式计算
+		n, _ := strconv.Atoi(line[1:])
+		if n < 1 || n > len(r.history) {
+			r.errorf("no history entry %d", n)
+			return
+		}
+		expr := r.history[n-1]
+		fmt.Fprintln(r.stdout, expr)
+		r.eval(expr)
+	case strings.HasPrefix(line, ":"):
+		r.runCommand(line)
+	default:
+		r.eval(line)
+	}
+}
+
+// isHistoryRef 是否为 !n 形式的历史引用
+func isHistoryRef(line string) bool {
+	if len(line) < 2 || line[0] != '!' {
+		return false
+	}
+	for _, c := range line[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// runCommand 执行 ":" 开头的命令
+func (r *replSession) runCommand(line string) {
+	fields := strings.Fields(line)
+	args := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+	switch fields[0] {
+	case ":set":
+		parts := strings.SplitN(args, " ", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			r.errorf("usage: :set field value")
+			return
+		}
+		r.data[parts[0]] = parseValue(strings.TrimSpace(parts[1]))
+	case ":unset":
+		delete(r.data, args)
+	case ":load":
+		if args == "" {
+			r.errorf("usage: :load file")
+			return
+		}
+		data, err := r.loadData(args)
+		if err != nil {
+			r.errorf("%v", err)
+			return
+		}
+		r.data = data
+	case ":data":
+		b, _ := json.MarshalIndent(r.data, "", "  ")
+		fmt.Fprintln(r.stdout, string(b))
+	case ":funcs":
+		fmt.Fprintln(r.stdout, strings.Join(goparser.Funcs(), "\n"))
+	case ":history":
+		for i, expr := range r.history {
+			fmt.Fprintf(r.stdout, "%d  %s\n", i+1, expr)
+		}
+	case ":help":
+		fmt.Fprintln(r.stdout, replHelp)
+	default:
+		r.errorf("unknown command %s, :help 查看帮助", fields[0])
+	}
+}
+
+// eval 计算表达式并输出结果及计算过程
+func (r *replSession) eval(expr string) {
+	r.history = append(r.history, expr)
+
+	// 计算结果取自计算过程的根节点，表达式只计算一次
+	node, err := goparser.Explain(expr, r.data)
+	if err == nil {
+		err = node.Err
+	}
+	if err != nil {
+		r.errorf("%v", err)
+		return
+	}
+	fmt.Fprintf(r.stdout, "= %v\n", node.Value)
+
+	// 只有一个节点时计算过程与结果相同
+	if len(node.Children) > 0 {
+		fmt.Fprintln(r.stdout, node.String())
+	}
+}
+
+// errorf 输出错误
+func (r *replSession) errorf(format string, args ...interface{}) {
+	fmt.Fprintf(r.stdout, "error: "+format+"\n", args...)
+}
+
+// parseValue 按JSON解析变量值，解析失败时作为字符串
+func parseValue(s string) interface{} {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return s
+	}
+	return goparser.NormalizeJSON(v)
+}
@@ -0,0 +1,343 @@
+package goparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+)
+
+// ErrPolicyViolation 表达式违反编译策略
+var ErrPolicyViolation = errors.New("policy violation")
+
+// 编译策略名称定义
+const (
+	PolicyMaxLength   = "max_length"    // 表达式最大长度
+	PolicyMaxDepth    = "max_depth"     // 语法树最大深度
+	PolicyMaxNodes    = "max_nodes"     // 语法树最大节点数
+	PolicyMaxListSize = "max_list_size" // 数组字面量最大元素个数
+	PolicyFunc        = "func"          // 函数允许列表、禁止列表
+	PolicyVar         = "var"           // 变量允许列表、禁止列表
+)
+
+// PolicyError 表达式违反编译策略时返回的错误，可通过 errors.Is(err, ErrPolicyViolation) 判断
+type PolicyError struct {
+	Policy string // 违反的策略
+	Detail string // 详细说明
+}
+
+// Error 实现error接口
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrPolicyViolation, e.Policy, e.Detail)
+}
+
+// Is 支持 errors.Is(err, ErrPolicyViolation)
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+// compileOptions 编译选项
+type compileOptions struct {
+	maxLength   int
+	maxDepth    int
+	maxNodes    int
+	maxListSize int
+	allowFuncs  map[string]bool
+	denyFuncs   map[string]bool
+	allowVars   map[string]bool
+	denyVars    map[string]bool
+}
+
+// CompileOption 编译选项函数
+type CompileOption func(o *compileOptions)
+
+// LimitLength 限制表达式最大长度（字节数）
+func LimitLength(n int) CompileOption {
+	return func(o *compileOptions) { o.maxLength = n }
+}
+
+// LimitDepth 限制语法树最大深度
+func LimitDepth(n int) CompileOption {
+	return func(o *compileOptions) { o.maxDepth = n }
+}
+
+// LimitNodes 限制语法树最大节点数
+func LimitNodes(n int) CompileOption {
+	return func(o *compileOptions) { o.maxNodes = n }
+}
+
+// LimitListSize 限制数组字面量（如 in_array 的候选列表）最大元素个数
+func LimitListSize(n int) CompileOption {
+	return func(o *compileOptions) { o.maxListSize = n }
+}
+
+// AllowFuncs 函数允许列表，设置后只能调用列表中的函数；值方法以 ".方法名" 表示，如 ".HasPrefix"
+func AllowFuncs(names ...string) CompileOption {
+	return func(o *compileOptions) { o.allowFuncs = addNames(o.allowFuncs, names) }
+}
+
+// DenyFuncs 函数禁止列表，值方法以 ".方法名" 表示
+func DenyFuncs(names ...string) CompileOption {
+	return func(o *compileOptions) { o.denyFuncs = addNames(o.denyFuncs, names) }
+}
+
+// AllowVars 变量允许列表，设置后只能引用列表中的变量
+func AllowVars(names ...string) CompileOption {
+	return func(o *compileOptions) { o.allowVars = addNames(o.allowVars, names) }
+}
+
+// DenyVars 变量禁止列表
+func DenyVars(names ...string) CompileOption {
+	return func(o *compileOptions) { o.denyVars = addNames(o.denyVars, names) }
+}
+
+// addNames 向名称集合中添加名称
+func addNames(set map[string]bool, names []string) map[string]bool {
+	if set == nil {
+		set = make(map[string]bool, len(names))
+	}
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// Program 预编译的表达式，编译后只读，可被多个协程并发使用。
+// 语法树会被编译为指令序列，由虚拟机执行，常用类型的运算不产生内存分配
+type Program struct {
+	expr  string
+	root  ast.Expr
+	code  *bytecode
+	exact *bytecode // 未经优化的语法树编译的指令序列，设置步数限制时使用，保证步数与 Eval 一致
+}
+
+// Compile 编译表达式，拒绝规则语言不支持的语法（见 Validate），并在计算前按照编译选项校验表达式复杂度及函数、变量的使用。
+// 校验通过的表达式会经过 Optimize 优化
+func Compile(expr string, opts ...CompileOption) (p *Program, err error) {
+	defer recoverPanic(&err, expr)
+
+	var o compileOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if expr == "" {
+		return nil, errors.New("empty expression")
+	}
+	if o.maxLength > 0 && len(expr) > o.maxLength {
+		return nil, &PolicyError{Policy: PolicyMaxLength, Detail: fmt.Sprintf("length %d exceeds %d", len(expr), o.maxLength)}
+	}
+
+	root, fset, err := parseExprWithFileSet(expr)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateExpr(root, fset); err != nil {
+		return nil, err
+	}
+	if err := o.check(root); err != nil {
+		return nil, err
+	}
+	optimized := optimizeExpr(root)
+	return &Program{expr: expr, root: optimized, code: compileBytecode(optimized), exact: compileBytecode(root)}, nil
+}
+
+// check 校验语法树是否满足编译选项
+func (o *compileOptions) check(root ast.Expr) error {
+	stats := inspectExpr(root)
+	if o.maxDepth > 0 && stats.depth > o.maxDepth {
+		return &PolicyError{Policy: PolicyMaxDepth, Detail: fmt.Sprintf("depth %d exceeds %d", stats.depth, o.maxDepth)}
+	}
+	if o.maxNodes > 0 && stats.nodes > o.maxNodes {
+		return &PolicyError{Policy: PolicyMaxNodes, Detail: fmt.Sprintf("node count %d exceeds %d", stats.nodes, o.maxNodes)}
+	}
+	if o.maxListSize > 0 && stats.maxList > o.maxListSize {
+		return &PolicyError{Policy: PolicyMaxListSize, Detail: fmt.Sprintf("list size %d exceeds %d", stats.maxList, o.maxListSize)}
+	}
+	for _, name := range stats.funcs {
+		if o.denyFuncs[name] || (o.allowFuncs != nil && !o.allowFuncs[name]) {
+			return &PolicyError{Policy: PolicyFunc, Detail: fmt.Sprintf("func %s is not allowed", name)}
+		}
+	}
+	for _, name := range stats.vars {
+		if o.denyVars[name] || (o.allowVars != nil && !o.allowVars[name]) {
+			return &PolicyError{Policy: PolicyVar, Detail: fmt.Sprintf("var %s is not allowed", name)}
+		}
+	}
+	return nil
+}
+
+// exprStats 语法树统计信息
+type exprStats struct {
+	depth   int      // 最大深度
+	nodes   int      // 节点数
+	maxList int      // 数组字面量最大元素个数
+	funcs   []string // 调用的函数，值方法以 ".方法名" 表示
+	vars    []string // 引用的变量
+}
+
+// inspectExpr 遍历语法树，统计深度、节点数及引用的函数、变量
+func inspectExpr(root ast.Expr) exprStats {
+	stats := exprStats{}
+	seen := make(map[string]bool)
+	stats.walk(root, 1, seen)
+	return stats
+}
+
+// walk 递归统计节点，depth 为当前节点所在深度
+func (s *exprStats) walk(node ast.Node, depth int, seen map[string]bool) {
+	s.nodes++
+	if depth > s.depth {
+		s.depth = depth
+	}
+
+	switch node := node.(type) {
+	case *ast.CompositeLit:
+		// 数组类型声明（如 []int）不计入变量
+		if len(node.Elts) > s.maxList {
+			s.maxList = len(node.Elts)
+		}
+		for _, elt := range node.Elts {
+			s.walk(elt, depth+1, seen)
+		}
+		return
+	case *ast.CallExpr:
+		name, ok := selectorName(node.Fun)
+		_, has := funcNameMap[name]
+		if sel, isSel := node.Fun.(*ast.SelectorExpr); isSel && (!ok || !has) {
+			// 值方法调用：接收者中的变量需继续统计
+			s.addFunc("."+sel.Sel.Name, seen)
+			s.walk(sel.X, depth+1, seen)
+		} else {
+			s.addFunc(name, seen)
+		}
+		for _, arg := range node.Args {
+			s.walk(arg, depth+1, seen)
+		}
+		return
+	case *ast.SelectorExpr:
+		// 命名空间常量不计入变量，其余选择器只统计左侧表达式
+		if name, ok := selectorName(node); ok {
+			if _, has := constNameMap[name]; has {
+				return
+			}
+		}
+		s.walk(node.X, depth+1, seen)
+		return
+	case *ast.Ident:
+		if node.Name != "true" && node.Name != "false" && !seen["var:"+node.Name] {
+			seen["var:"+node.Name] = true
+			s.vars = append(s.vars, node.Name)
+		}
+		return
+	}
+
+	// 其余节点遍历直接子节点
+	ast.Inspect(node, func(child ast.Node) bool {
+		if child == node {
+			return true
+		}
+		if child != nil {
+			s.walk(child, depth+1, seen)
+		}
+		return false
+	})
+}
+
+// addFunc 记录调用的函数
+func (s *exprStats) addFunc(name string, seen map[string]bool) {
+	if !seen["func:"+name] {
+		seen["func:"+name] = true
+		s.funcs = append(s.funcs, name)
+	}
+}
+
+// String 返回编译前的表达式
+func (p *Program) String() string {
+	return p.expr
+}
+
+// Match 使用编译后的表达式完成与输入数据的匹配，语义与 Match 一致
+func (p *Program) Match(data map[string]interface{}) (bool, error) {
+	return p.MatchContext(context.Background(), data)
+}
+
+// MatchContext 在上下文中使用编译后的表达式完成与输入数据的匹配
+func (p *Program) MatchContext(ctx context.Context, data map[string]interface{}) (ok bool, err error) {
+	defer p.recoverPanic(&err)
+
+	// 空数据默认匹配失败
+	if data == nil {
+		return false, nil
+	}
+	return observeRule(ctx, p.expr, p.expr, func() (bool, error) {
+		return p.match(ctx, data)
+	})
+}
+
+// match 完成匹配，上下文不可取消时直接在当前协程中计算
+func (p *Program) match(ctx context.Context, data map[string]interface{}) (bool, error) {
+	if ctx.Done() == nil {
+		return p.matchState(ctx, data)
+	}
+	result := runWithContext(ctx, p.expr, func() interface{} {
+		return p.bytecode(ctx).run(ctx, data).box()
+	})
+	if err, ok := result.(error); ok {
+		return false, err
+	}
+	return resultToBool(p.expr, result)
+}
+
+// Eval 使用编译后的表达式计算并返回原始结果
+func (p *Program) Eval(data map[string]interface{}) (interface{}, error) {
+	return p.EvalContext(context.Background(), data)
+}
+
+// EvalContext 在上下文中使用编译后的表达式计算并返回原始结果
+func (p *Program) EvalContext(ctx context.Context, data map[string]interface{}) (v interface{}, err error) {
+	defer p.recoverPanic(&err)
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	result := runWithContext(ctx, p.expr, func() interface{} {
+		return p.bytecode(ctx).run(ctx, data).box()
+	})
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	return result, nil
+}
+
+// matchState 在当前协程中完成匹配，沿用上下文中的计算状态，供规则集批量计算使用
+func (p *Program) matchState(ctx context.Context, data map[string]interface{}) (ok bool, err error) {
+	defer p.recoverPanic(&err)
+
+	result := p.bytecode(ctx).run(ctx, data)
+	switch result.kind {
+	case vBool:
+		return result.n != 0, nil
+	case vAny:
+		if err, isErr := result.ref.(error); isErr {
+			return false, err
+		}
+	}
+	return resultToBool(p.expr, result.box())
+}
+
+// bytecode 选择执行的指令序列：优化会折叠常量、去除括号，改变访问的节点数，
+// 设置步数限制时使用未经优化的指令序列，同一表达式是否超出步数限制与是否预编译无关
+func (p *Program) bytecode(ctx context.Context) *bytecode {
+	if state := stateFrom(ctx); state != nil && state.maxSteps > 0 {
+		return p.exact
+	}
+	return p.code
+}
+
+// recoverPanic 将计算过程中的panic转换为 *PanicError，避免每次计算时装箱表达式字符串
+func (p *Program) recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Expr: p.expr, Value: r}
+	}
+}
@@ -0,0 +1,117 @@
+package goparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"sync/atomic"
+)
+
+// ErrStepLimitExceeded 表达式计算步数超出限制
+var ErrStepLimitExceeded = errors.New("step limit exceeded")
+
+// stateKey 上下文中计算状态的键
+type stateKey struct{}
+
+// evalState 通过上下文传递的计算状态
+type evalState struct {
+	maxSteps int64  // 最大计算步数，每访问一个语法树节点计一步
+	steps    *int64 // 已计算步数，派生的状态共享同一计数器
+
+	shared map[ast.Expr]string    // 可复用计算结果的公共子表达式 => 规范化表达式
+	cache  map[string]interface{} // 公共子表达式的计算结果
+
+	resolve  *resolveCache // 变量解析器，见 WithResolver
+	observer Observer      // 观察者，见 WithObserver
+}
+
+// stateFrom 获取上下文中的计算状态，不存在时返回nil
+func stateFrom(ctx context.Context) *evalState {
+	state, _ := ctx.Value(stateKey{}).(*evalState)
+	return state
+}
+
+// withState 基于上下文中已有的计算状态派生新的状态
+func withState(ctx context.Context, update func(state *evalState)) context.Context {
+	state := &evalState{steps: new(int64)}
+	if parent := stateFrom(ctx); parent != nil {
+		*state = *parent
+	}
+	update(state)
+	return context.WithValue(ctx, stateKey{}, state)
+}
+
+// WithMaxSteps 为上下文设置最大计算步数（访问的语法树节点数），用于限制不可信规则的计算开销。
+// 步数在使用同一上下文的多次计算间累计，通常每次计算创建一个新的上下文
+func WithMaxSteps(ctx context.Context, max int64) context.Context {
+	return withState(ctx, func(state *evalState) {
+		state.maxSteps = max
+		state.steps = new(int64)
+	})
+}
+
+// visitNode 访问语法树节点前检查上下文状态及步数限制
+func visitNode(ctx context.Context, state *evalState) error {
+	return visitNodes(ctx, state, 1)
+}
+
+// visitNodes 一次访问 n 个语法树节点，检查上下文状态及步数限制
+func visitNodes(ctx context.Context, state *evalState, n int64) error {
+	if done := ctx.Done(); done != nil {
+		select {
+		case <-done:
+			return ctx.Err()
+		default:
+		}
+	}
+	if state != nil && state.maxSteps > 0 {
+		if steps := atomic.AddInt64(state.steps, n); steps > state.maxSteps {
+			return fmt.Errorf("%w: max %d", ErrStepLimitExceeded, state.maxSteps)
+		}
+	}
+	return nil
+}
+
+// isAbort 计算结果是否为上下文结束或超出步数限制的错误，此时计算立即终止，错误原样向上传递
+func isAbort(v interface{}) bool {
+	err, ok := v.(error)
+	return ok && (errors.Is(err, ErrStepLimitExceeded) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded))
+}
+
+// evalWithContext 计算表达式，上下文可被取消时的处理见 runWithContext
+func evalWithContext(ctx context.Context, src string, expr ast.Expr, data map[string]interface{}) interface{} {
+	return runWithContext(ctx, src, func() interface{} {
+		return EvalContext(ctx, expr, data)
+	})
+}
+
+// runWithContext 执行计算。上下文可被取消时在独立协程中计算，
+// 以便自定义函数阻塞时调用方也能在上下文结束后及时返回
+func runWithContext(ctx context.Context, src string, run func() interface{}) interface{} {
+	if ctx.Done() == nil {
+		return run()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ch := make(chan interface{}, 1)
+	go func() {
+		var err error
+		defer func() {
+			if err != nil {
+				ch <- err
+			}
+		}()
+		defer recoverPanic(&err, src)
+		ch <- run()
+	}()
+
+	select {
+	case result := <-ch:
+		return result
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
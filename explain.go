@@ -0,0 +1,242 @@
+package goparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"strings"
+)
+
+// ExplainNode 表达式计算过程中的语法树节点，结构与 go/ast 语法树一致
+type ExplainNode struct {
+	Expr           string         `json:"expr"`                      // 节点的表达式
+	Value          interface{}    `json:"value,omitempty"`           // 计算结果
+	Err            error          `json:"-"`                         // 计算出错时的错误，JSON中序列化为 error 字段
+	ShortCircuited bool           `json:"short_circuited,omitempty"` // && 左侧为false或 || 左侧为true时，右侧节点未被计算
+	Children       []*ExplainNode `json:"children,omitempty"`        // 子节点，与语法树结构一致，函数调用的子节点为值方法的接收者及各参数
+
+	node ast.Expr
+}
+
+// Explain 计算表达式并返回每个节点的计算过程，用于排查规则不匹配的原因。
+// 计算语义与 Match 一致：&& 左侧为false或 || 左侧为true时右侧不会被计算，标记为 ShortCircuited
+func Explain(expr string, data map[string]interface{}) (*ExplainNode, error) {
+	return ExplainContext(context.Background(), expr, data)
+}
+
+// ExplainContext 在上下文中计算表达式并返回每个节点的计算过程
+func ExplainContext(ctx context.Context, expr string, data map[string]interface{}) (*ExplainNode, error) {
+	return explainExpr(ctx, expr, data, false)
+}
+
+// explainExpr 解析并计算表达式，eager 表示依然计算被短路的右侧节点，用于 WhyNot 列出全部不成立的条件
+func explainExpr(ctx context.Context, expr string, data map[string]interface{}, eager bool) (node *ExplainNode, err error) {
+	defer recoverPanic(&err, expr)
+
+	root, fset, err := parseExprWithFileSet(expr)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateExpr(root, fset); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	result := runWithContext(ctx, expr, func() interface{} {
+		return explainNode(ctx, root, data, eager)
+	})
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	return result.(*ExplainNode), nil
+}
+
+// explainNode 递归计算节点并记录计算结果，运算规则与 evalNode 一致
+func explainNode(ctx context.Context, expr ast.Expr, data map[string]interface{}, eager bool) *ExplainNode {
+	n := &ExplainNode{Expr: exprString(expr), node: expr}
+
+	var value interface{}
+	switch expr := expr.(type) {
+	case *ast.ParenExpr:
+		x := explainNode(ctx, expr.X, data, eager)
+		n.Children = []*ExplainNode{x}
+		value = x.result()
+	case *ast.BinaryExpr:
+		x := explainNode(ctx, expr.X, data, eager)
+		b, short := shortCircuit(x.result(), expr.Op)
+		var y *ExplainNode
+		if short && !eager {
+			y = &ExplainNode{Expr: exprString(expr.Y), ShortCircuited: true, node: expr.Y}
+		} else {
+			y = explainNode(ctx, expr.Y, data, eager)
+		}
+		n.Children = []*ExplainNode{x, y}
+		if short {
+			value = b
+		} else {
+			value = evalBinary(x.result(), y.result(), expr.Op)
+		}
+	case *ast.UnaryExpr:
+		x := explainNode(ctx, expr.X, data, eager)
+		n.Children = []*ExplainNode{x}
+		value = evalUnary(expr, x.result())
+	case *ast.CallExpr:
+		// 函数按其自身语义整体计算（如 if 只计算选中的分支），子节点为值方法的接收者及各参数，单独计算用于展示
+		value = EvalContext(ctx, expr, data)
+		if sel, ok := expr.Fun.(*ast.SelectorExpr); ok && isMethodCall(sel) {
+			n.Children = append(n.Children, explainNode(ctx, sel.X, data, eager))
+		}
+		for _, arg := range expr.Args {
+			n.Children = append(n.Children, explainNode(ctx, arg, data, eager))
+		}
+	case *ast.CompositeLit:
+		value = EvalContext(ctx, expr, data)
+		for _, elt := range expr.Elts {
+			n.Children = append(n.Children, explainNode(ctx, elt, data, eager))
+		}
+	default:
+		value = EvalContext(ctx, expr, data)
+	}
+
+	if err, ok := value.(error); ok {
+		n.Err = err
+	} else {
+		n.Value = value
+	}
+	return n
+}
+
+// isMethodCall 选择器是否按值方法调用，未注册为命名空间函数的选择器均为值方法，与 evalNode 一致
+func isMethodCall(sel *ast.SelectorExpr) bool {
+	name, ok := selectorName(sel)
+	if !ok {
+		return true
+	}
+	_, has := funcNameMap[name]
+	return !has
+}
+
+// result 节点的计算结果，出错时返回error
+func (n *ExplainNode) result() interface{} {
+	if n.Err != nil {
+		return n.Err
+	}
+	return n.Value
+}
+
+// MarshalJSON 实现json.Marshaler接口，计算出错时错误信息序列化为 error 字段
+func (n *ExplainNode) MarshalJSON() ([]byte, error) {
+	type explainNode ExplainNode
+	v := struct {
+		*explainNode
+		Error string `json:"error,omitempty"`
+	}{explainNode: (*explainNode)(n)}
+	if n.Err != nil {
+		v.Error = n.Err.Error()
+	}
+	// 是否转义HTML字符由调用方的编码器决定
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// String 以缩进的文本形式展示计算过程，未被计算的节点不输出结果，如：
+//
+//	age >= 18 && level > 3 → false
+//	  age >= 18 → false (age = 16)
+//	  level > 3 [short-circuited]
+func (n *ExplainNode) String() string {
+	var b strings.Builder
+	n.render(&b, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// render 输出节点及其子节点，变量、字面量作为父节点的补充说明，不单独输出
+func (n *ExplainNode) render(b *strings.Builder, depth int) {
+	if n.ShortCircuited {
+		b.WriteString(strings.Repeat("  ", depth) + n.Expr + " [short-circuited]\n")
+		return
+	}
+
+	// 括号节点按其内部表达式输出
+	node := n
+	for len(node.Children) == 1 && isParen(node.node) {
+		node = node.Children[0]
+	}
+
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(node.Expr)
+	b.WriteString(" → ")
+	b.WriteString(formatExplainValue(node.result()))
+
+	var vars []string
+	var nested []*ExplainNode
+	for _, child := range node.Children {
+		if child.ShortCircuited {
+			nested = append(nested, child)
+			continue
+		}
+		switch unparen(child.node).(type) {
+		case *ast.Ident:
+			if _, isConst := boolConst(child.node); !isConst {
+				vars = append(vars, fmt.Sprintf("%s = %s", exprString(unparen(child.node)), formatExplainValue(child.result())))
+			}
+		case *ast.BasicLit:
+		case *ast.CompositeLit:
+			// 只包含字面量的数组与字面量一样不单独输出
+			if !isConstList(child.node) {
+				nested = append(nested, child)
+			}
+		default:
+			nested = append(nested, child)
+		}
+	}
+	if len(vars) > 0 {
+		b.WriteString(" (" + strings.Join(vars, ", ") + ")")
+	}
+	b.WriteString("\n")
+
+	for _, child := range nested {
+		child.render(b, depth+1)
+	}
+}
+
+// isConstList 是否为只包含字面量的数组字面量
+func isConstList(expr ast.Expr) bool {
+	lit, ok := unparen(expr).(*ast.CompositeLit)
+	if !ok {
+		return false
+	}
+	for _, elt := range lit.Elts {
+		if !isConstExpr(elt) {
+			return false
+		}
+	}
+	return true
+}
+
+// isParen 是否为括号节点
+func isParen(expr ast.Expr) bool {
+	_, ok := expr.(*ast.ParenExpr)
+	return ok
+}
+
+// formatExplainValue 格式化计算结果，字符串加引号
+func formatExplainValue(v interface{}) string {
+	switch v := v.(type) {
+	case error:
+		return "error: " + v.Error()
+	case string:
+		return fmt.Sprintf("%q", v)
+	case nil:
+		return "nil"
+	}
+	return fmt.Sprint(v)
+}
@@ -2,6 +2,11 @@ package goparser
 
 import (
 	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
 
 	jsoniter "github.com/json-iterator/go"
 
@@ -37,7 +42,9 @@ var LogicMaps = map[string]string{
 }
 
 // Expression 基于JSON生成表达式字符串
-func Expression(exp map[string]interface{}) (string, error) {
+func Expression(exp map[string]interface{}) (res string, err error) {
+	defer recoverPanic(&err, exp)
+
 	if len(exp) < 2 {
 		return "", errors.New("invalid params")
 	}
@@ -53,11 +60,11 @@ func Expression(exp map[string]interface{}) (string, error) {
 	}
 
 	var con string = ""
-	if v, has := LogicMaps[exp["connector"].(string)]; has {
+	connector, _ := exp["connector"].(string)
+	if v, has := LogicMaps[connector]; has {
 		con = v
 	}
 
-	res := ""
 	for _, item := range childs {
 		val, ok := item.(map[string]interface{})
 		if !ok {
@@ -76,6 +83,10 @@ func Expression(exp map[string]interface{}) (string, error) {
 			}
 
 			if con == LogicNot {
+				// 只有一个条件的分组没有外层括号，取反前需补充
+				if !strings.HasPrefix(ss, "(") {
+					ss = StringBuilder("(", ss, ")")
+				}
 				res = LogicNot + ss
 			} else {
 				if len(res) == 0 {
@@ -103,8 +114,10 @@ func Expression(exp map[string]interface{}) (string, error) {
 }
 
 // ExportFields 导出表达中参数名
-func ExportFields(exp map[string]interface{}) ([]string, error) {
-	res := make([]string, 0)
+func ExportFields(exp map[string]interface{}) (res []string, err error) {
+	defer recoverPanic(&err, exp)
+
+	res = make([]string, 0)
 	if len(exp) < 2 {
 		return res, errors.New("invalid params")
 	}
@@ -155,28 +168,149 @@ func ExportFields(exp map[string]interface{}) ([]string, error) {
 	return res, nil
 }
 
-// convert 结构体转换为表达式
-func convert(item ChildrenItem) string {
-	opMap := map[string]string{
-		"NE":  "!=",
-		"EQ":  "==",
-		"GT":  ">",
-		"LT":  "<",
-		"GE":  ">=",
-		"LE":  "<=",
-		"ADD": "+",
-		"SUB": "-",
-		"MUL": "*",
-		"QUO": "/",
+// ExportVars 导出表达式中引用的变量名，按首次出现的顺序排列
+func ExportVars(expr string) (res []string, err error) {
+	defer recoverPanic(&err, expr)
+
+	root, fset, err := parseExprWithFileSet(expr)
+	if err != nil {
+		return nil, err
 	}
+	if err := validateExpr(root, fset); err != nil {
+		return nil, err
+	}
+	return append([]string{}, inspectExpr(root).vars...), nil
+}
+
+// itemOps 条件运算符标识与表达式运算符的对应关系
+var itemOps = map[string]string{
+	"NE":  "!=",
+	"EQ":  "==",
+	"GT":  ">",
+	"LT":  "<",
+	"GE":  ">=",
+	"LE":  "<=",
+	"ADD": "+",
+	"SUB": "-",
+	"MUL": "*",
+	"QUO": "/",
+	"REM": "%",
+
+	"AND":     "&",
+	"OR":      "|",
+	"XOR":     "^",
+	"SHL":     "<<",
+	"SHR":     ">>",
+	"AND_NOT": "&^",
+}
 
+// convert 结构体转换为表达式
+func convert(item ChildrenItem) string {
 	var val interface{}
 	switch item.Value.(type) {
 	case string:
-		val = StringBuilder("\"", item.Value, "\"")
+		val = strconv.Quote(item.Value.(string))
 	default:
 		val = item.Value
 	}
 
-	return StringBuilder(item.Field, " ", opMap[item.Op], " ", val)
+	return StringBuilder(item.Field, " ", itemOps[item.Op], " ", val)
+}
+
+// ExpressionTree 将表达式转换为 Expression 使用的JSON条件树，与 Expression 互为逆操作。
+// && 与 || 链转换为 AND、OR 分组，! 转换为 NOT 分组，叶子条件须为 `变量 运算符 字面量` 的形式
+func ExpressionTree(expr string) (res map[string]interface{}, err error) {
+	defer recoverPanic(&err, expr)
+
+	root, fset, err := parseExprWithFileSet(expr)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateExpr(root, fset); err != nil {
+		return nil, err
+	}
+	node, err := exprToTree(root)
+	if err != nil {
+		return nil, err
+	}
+	if _, isGroup := node["children"]; isGroup {
+		return node, nil
+	}
+	return map[string]interface{}{"connector": LogicAndName, "children": []interface{}{node}}, nil
+}
+
+// exprToTree 将语法树节点转换为条件分组或叶子条件
+func exprToTree(expr ast.Expr) (map[string]interface{}, error) {
+	expr = unparen(expr)
+	switch node := expr.(type) {
+	case *ast.BinaryExpr:
+		if node.Op == token.LAND || node.Op == token.LOR {
+			var operands []ast.Expr
+			flattenChain(node.Op, node, &operands)
+			children := make([]interface{}, 0, len(operands))
+			for _, operand := range operands {
+				child, err := exprToTree(operand)
+				if err != nil {
+					return nil, err
+				}
+				children = append(children, child)
+			}
+			connector := LogicAndName
+			if node.Op == token.LOR {
+				connector = LogicOrName
+			}
+			return map[string]interface{}{"connector": connector, "children": children}, nil
+		}
+		return leafToTree(node)
+	case *ast.UnaryExpr:
+		if node.Op == token.NOT {
+			child, err := exprToTree(node.X)
+			if err != nil {
+				return nil, err
+			}
+			// NOT 分组只对子分组取反，叶子条件需放入分组中
+			if _, isGroup := child["children"]; !isGroup {
+				child = map[string]interface{}{"connector": LogicAndName, "children": []interface{}{child}}
+			}
+			return map[string]interface{}{"connector": LogicNotName, "children": []interface{}{child}}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s cannot be converted to condition", exprString(expr))
+}
+
+// leafToTree 将 `变量 运算符 字面量` 形式的二元表达式转换为叶子条件
+func leafToTree(expr *ast.BinaryExpr) (map[string]interface{}, error) {
+	op := opName(expr.Op)
+	if _, ok := itemOps[op]; !ok {
+		return nil, fmt.Errorf("%s: op %s is not support", exprString(expr), expr.Op)
+	}
+	value, ok := treeValue(unparen(expr.Y))
+	if !ok {
+		return nil, fmt.Errorf("%s: right operand must be a literal", exprString(expr))
+	}
+	return map[string]interface{}{"op": op, "field": exprString(unparen(expr.X)), "value": value}, nil
+}
+
+// treeValue 叶子条件中字面量的值
+func treeValue(expr ast.Expr) (interface{}, bool) {
+	switch v := expr.(type) {
+	case *ast.BasicLit:
+		value := getlitValue(v)
+		_, isErr := value.(error)
+		return value, !isErr
+	case *ast.Ident:
+		b, ok := boolConst(v)
+		return b, ok
+	case *ast.UnaryExpr:
+		// 负数字面量
+		if lit, ok := v.X.(*ast.BasicLit); ok && v.Op == token.SUB && lit.Kind != token.STRING {
+			switch n := getlitValue(lit).(type) {
+			case int64:
+				return -n, true
+			case float64:
+				return -n, true
+			}
+		}
+	}
+	return nil, false
 }
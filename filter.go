@@ -0,0 +1,160 @@
+package goparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// RecordIterator 记录迭代器，Next 返回下一条记录，没有更多记录时第二个返回值为false。
+// Next 只会在单个协程中被调用
+type RecordIterator interface {
+	Next() (map[string]interface{}, bool)
+}
+
+// IterFunc 函数形式的记录迭代器
+type IterFunc func() (map[string]interface{}, bool)
+
+// Next 实现 RecordIterator 接口
+func (f IterFunc) Next() (map[string]interface{}, bool) {
+	return f()
+}
+
+// sliceIterator 切片记录迭代器
+type sliceIterator struct {
+	records []map[string]interface{}
+	pos     int
+}
+
+// Next 实现 RecordIterator 接口
+func (it *sliceIterator) Next() (map[string]interface{}, bool) {
+	if it.pos >= len(it.records) {
+		return nil, false
+	}
+	it.pos++
+	return it.records[it.pos-1], true
+}
+
+// RecordError 单条记录计算出错
+type RecordError struct {
+	Index int // 记录下标
+	Err   error
+}
+
+// Error 实现error接口
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %v", e.Index, e.Err)
+}
+
+// Unwrap 支持 errors.Is、errors.As
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// FilterResult 批量过滤结果
+type FilterResult struct {
+	Matches []int          // 匹配的记录下标，按升序排列
+	Errors  []*RecordError // 计算出错的记录，按下标升序排列，出错的记录视为不匹配
+}
+
+// filterOptions 批量过滤选项
+type filterOptions struct {
+	workers int
+}
+
+// FilterOption 批量过滤选项函数
+type FilterOption func(o *filterOptions)
+
+// Workers 设置并发计算的协程数，默认为 runtime.GOMAXPROCS(0)
+func Workers(n int) FilterOption {
+	return func(o *filterOptions) { o.workers = n }
+}
+
+// FilterSlice 使用编译后的表达式并发过滤记录切片
+func FilterSlice(p *Program, records []map[string]interface{}, opts ...FilterOption) (*FilterResult, error) {
+	return FilterSliceContext(context.Background(), p, records, opts...)
+}
+
+// FilterSliceContext 在上下文中使用编译后的表达式并发过滤记录切片
+func FilterSliceContext(ctx context.Context, p *Program, records []map[string]interface{}, opts ...FilterOption) (*FilterResult, error) {
+	return FilterIterContext(ctx, p, &sliceIterator{records: records}, opts...)
+}
+
+// FilterIter 使用编译后的表达式并发过滤迭代器中的记录，记录下标为迭代顺序
+func FilterIter(p *Program, iter RecordIterator, opts ...FilterOption) (*FilterResult, error) {
+	return FilterIterContext(context.Background(), p, iter, opts...)
+}
+
+// FilterIterContext 在上下文中使用编译后的表达式并发过滤迭代器中的记录，上下文结束时停止读取并返回上下文错误
+func FilterIterContext(ctx context.Context, p *Program, iter RecordIterator, opts ...FilterOption) (*FilterResult, error) {
+	if p == nil {
+		return nil, errors.New("nil program")
+	}
+	o := filterOptions{workers: runtime.GOMAXPROCS(0)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.workers < 1 {
+		o.workers = 1
+	}
+
+	type record struct {
+		index int
+		data  map[string]interface{}
+	}
+	records := make(chan record, o.workers*4)
+	results := make([]FilterResult, o.workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < o.workers; i++ {
+		wg.Add(1)
+		go func(result *FilterResult) {
+			defer wg.Done()
+			for r := range records {
+				// 上下文结束后只消费剩余记录
+				if ctx.Err() != nil || r.data == nil {
+					continue
+				}
+				ok, err := observeRule(ctx, p.expr, p.expr, func() (bool, error) {
+					return p.matchState(withRecordResolver(ctx), r.data)
+				})
+				switch {
+				case err != nil:
+					result.Errors = append(result.Errors, &RecordError{Index: r.index, Err: err})
+				case ok:
+					result.Matches = append(result.Matches, r.index)
+				}
+			}
+		}(&results[i])
+	}
+
+	// 迭代器只在当前协程中读取
+	for index := 0; ctx.Err() == nil; index++ {
+		data, ok := iter.Next()
+		if !ok {
+			break
+		}
+		records <- record{index: index, data: data}
+	}
+	close(records)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 合并各协程的结果并恢复记录顺序
+	merged := &FilterResult{}
+	for _, result := range results {
+		merged.Matches = append(merged.Matches, result.Matches...)
+		merged.Errors = append(merged.Errors, result.Errors...)
+	}
+	sort.Ints(merged.Matches)
+	sort.Slice(merged.Errors, func(i, j int) bool {
+		return merged.Errors[i].Index < merged.Errors[j].Index
+	})
+	return merged, nil
+}
@@ -1,31 +1,95 @@
 package goparser
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"go/ast"
+	"sort"
 )
 
 // Func 生命自定义函数类型
 type Func func(args []ast.Expr, data map[string]interface{}) interface{}
 
+// FuncContext 支持上下文的自定义函数类型，函数内应通过 EvalContext 计算参数，
+// 以便继承调用方的取消、超时及步数限制
+type FuncContext func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{}
+
 // 注册可执行函数
-var funcNameMap = make(map[string]Func, 10)
+var funcNameMap = make(map[string]FuncContext, 10)
 
 // init 自定义函数初始化
 func init() {
 	// 注册内置函数
-	RegisterFunc("in_array", inArray)
+	RegisterFuncContext("in_array", inArray)
+
+	// 注册数学函数
+	RegisterFuncContext("abs", mathAbs)
+	RegisterFuncContext("min", mathMin)
+	RegisterFuncContext("max", mathMax)
+	RegisterFuncContext("pow", mathPow)
+	RegisterFuncContext("sqrt", mathSqrt)
+	RegisterFuncContext("round", mathRound)
+	RegisterFuncContext("floor", mathFloor)
+	RegisterFuncContext("ceil", mathCeil)
+	RegisterFuncContext("clamp", mathClamp)
+	RegisterFuncContext("sum", mathSum)
+	RegisterFuncContext("avg", mathAvg)
+
+	// 注册条件函数
+	RegisterFuncContext("if", condIf)
+	RegisterFuncContext("coalesce", condCoalesce)
+	RegisterFuncContext("default", condDefault)
 }
 
-// RegisterFunc 注册自定义函数
+// 注册命名空间常量，如 time.Hour、math.Pi
+var constNameMap = make(map[string]interface{}, 10)
+
+// RegisterFunc 注册自定义函数，name 支持以 "." 分隔的命名空间，如 "strings.HasPrefix"
 func RegisterFunc(name string, f Func) {
+	funcNameMap[name] = func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		return f(args, data)
+	}
+}
+
+// RegisterFuncContext 注册支持上下文的自定义函数，name 规则同 RegisterFunc
+func RegisterFuncContext(name string, f FuncContext) {
 	funcNameMap[name] = f
 }
 
+// Funcs 返回已注册的函数名，按名称排序
+func Funcs() []string {
+	names := make([]string, 0, len(funcNameMap))
+	for name := range funcNameMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// evalArgs 校验参数个数并依次计算函数参数，任一参数计算出错时返回该错误
+func evalArgs(ctx context.Context, name string, args []ast.Expr, data map[string]interface{}, n int) ([]interface{}, error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("func %s expects %d params, got %d", name, n, len(args))
+	}
+	values := make([]interface{}, 0, n)
+	for _, arg := range args {
+		v := EvalContext(ctx, arg, data)
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
 // inArray 判断变量是否存在在数组中
-func inArray(args []ast.Expr, data map[string]interface{}) interface{} {
+func inArray(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	if len(args) != 2 {
+		return fmt.Errorf("func in_array expects 2 params, got %d", len(args))
+	}
 	// 规则表达式中的变量
-	param := Eval(args[0], data)
+	param := EvalContext(ctx, args[0], data)
 	vRange, ok := args[1].(*ast.CompositeLit)
 	if !ok {
 		return errors.New("func in_array 2ed params is not a composite lit")
@@ -34,7 +98,7 @@ func inArray(args []ast.Expr, data map[string]interface{}) interface{} {
 	// 规则表达式中数组里的元素
 	eltNodes := make([]interface{}, 0, len(vRange.Elts))
 	for _, p := range vRange.Elts {
-		elt := Eval(p, data)
+		elt := EvalContext(ctx, p, data)
 		eltNodes = append(eltNodes, elt)
 	}
 
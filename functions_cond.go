@@ -0,0 +1,47 @@
+package goparser
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+)
+
+// condIf 条件函数：if(cond, a, b)，cond 为真时返回 a，否则返回 b，未选中的分支不会被计算
+func condIf(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	if len(args) != 3 {
+		return fmt.Errorf("func if expects 3 params, got %d", len(args))
+	}
+	cond := EvalContext(ctx, args[0], data)
+	if err, ok := cond.(error); ok {
+		return err
+	}
+	ok, err := castToBoolean(cond)
+	if err != nil {
+		return fmt.Errorf("func if 1st params: %w", err)
+	}
+	if ok {
+		return EvalContext(ctx, args[1], data)
+	}
+	return EvalContext(ctx, args[2], data)
+}
+
+// condCoalesce 合并函数：coalesce(a, b, ...)，按顺序返回第一个非nil的值
+func condCoalesce(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	if len(args) == 0 {
+		return fmt.Errorf("func coalesce expects at least 1 params")
+	}
+	for _, arg := range args {
+		if v := EvalContext(ctx, arg, data); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// condDefault 默认值函数：default(x, v)，x 不存在（为nil）时返回 v
+func condDefault(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	if len(args) != 2 {
+		return fmt.Errorf("func default expects 2 params, got %d", len(args))
+	}
+	return condCoalesce(ctx, args, data)
+}
@@ -0,0 +1,344 @@
+package goparser
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// number 数学函数的统一数值表示，整数保持int64精度，其余按float64处理
+type number struct {
+	i       int64
+	f       float64
+	isFloat bool
+}
+
+// value 返回数值的实际类型值
+func (n number) value() interface{} {
+	if n.isFloat {
+		return n.f
+	}
+	return n.i
+}
+
+// float 返回数值的float64表示
+func (n number) float() float64 {
+	if n.isFloat {
+		return n.f
+	}
+	return float64(n.i)
+}
+
+// toNumber 将变量转为数值类型
+func toNumber(v interface{}) (number, error) {
+	switch t := v.(type) {
+	case int:
+		return number{i: int64(t)}, nil
+	case int8:
+		return number{i: int64(t)}, nil
+	case int16:
+		return number{i: int64(t)}, nil
+	case int32:
+		return number{i: int64(t)}, nil
+	case int64:
+		return number{i: t}, nil
+	case uint:
+		return uintToNumber(uint64(t))
+	case uint8:
+		return number{i: int64(t)}, nil
+	case uint16:
+		return number{i: int64(t)}, nil
+	case uint32:
+		return number{i: int64(t)}, nil
+	case uint64:
+		return uintToNumber(t)
+	case float32:
+		return number{f: float64(t), isFloat: true}, nil
+	case float64:
+		return number{f: t, isFloat: true}, nil
+	case json.Number:
+		return parseNumber(string(t))
+	case string:
+		return parseNumber(t)
+	}
+	return number{}, fmt.Errorf("type cast failure, unexpected number value: %v", v)
+}
+
+// uintToNumber 无符号整数转为数值，超出int64范围时返回错误
+func uintToNumber(v uint64) (number, error) {
+	if v > math.MaxInt64 {
+		return number{}, fmt.Errorf("%d: %w", v, ErrIntegerOverflow)
+	}
+	return number{i: int64(v)}, nil
+}
+
+// parseNumber 解析字符串形式的数值
+func parseNumber(s string) (number, error) {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return number{i: i}, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return number{}, fmt.Errorf("type cast failure, unexpected number value: %q", s)
+	}
+	return number{f: f, isFloat: true}, nil
+}
+
+// evalNumbers 计算函数参数并转为数值，参数为数组或切片时展开其中的元素
+func evalNumbers(ctx context.Context, args []ast.Expr, data map[string]interface{}) ([]number, error) {
+	nums := make([]number, 0, len(args))
+	for _, arg := range args {
+		v := EvalContext(ctx, arg, data)
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			n, err := toNumber(v)
+			if err != nil {
+				return nil, err
+			}
+			nums = append(nums, n)
+			continue
+		}
+		for i := 0; i < rv.Len(); i++ {
+			n, err := toNumber(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			nums = append(nums, n)
+		}
+	}
+	return nums, nil
+}
+
+// evalNumberArgs 计算固定个数的数值参数
+func evalNumberArgs(ctx context.Context, name string, args []ast.Expr, data map[string]interface{}, min, max int) ([]number, error) {
+	if len(args) < min || len(args) > max {
+		if min == max {
+			return nil, fmt.Errorf("func %s expects %d params, got %d", name, min, len(args))
+		}
+		return nil, fmt.Errorf("func %s expects %d to %d params, got %d", name, min, max, len(args))
+	}
+	nums := make([]number, 0, len(args))
+	for _, arg := range args {
+		v := EvalContext(ctx, arg, data)
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		n, err := toNumber(v)
+		if err != nil {
+			return nil, fmt.Errorf("func %s: %w", name, err)
+		}
+		nums = append(nums, n)
+	}
+	return nums, nil
+}
+
+// hasFloat 判断数值中是否存在浮点数
+func hasFloat(nums []number) bool {
+	for _, n := range nums {
+		if n.isFloat {
+			return true
+		}
+	}
+	return false
+}
+
+// mathAbs 绝对值：abs(x)
+func mathAbs(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	nums, err := evalNumberArgs(ctx, "abs", args, data, 1, 1)
+	if err != nil {
+		return err
+	}
+	n := nums[0]
+	if n.isFloat {
+		return math.Abs(n.f)
+	}
+	if n.i == math.MinInt64 {
+		return fmt.Errorf("abs(%d): %w", n.i, ErrIntegerOverflow)
+	}
+	if n.i < 0 {
+		return -n.i
+	}
+	return n.i
+}
+
+// mathMin 最小值：min(x, y, ...)，参数可以为数组
+func mathMin(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	return extremum(ctx, "min", args, data, true)
+}
+
+// mathMax 最大值：max(x, y, ...)，参数可以为数组
+func mathMax(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	return extremum(ctx, "max", args, data, false)
+}
+
+// extremum 求最小值或最大值，全部为整数时返回int64，否则返回float64
+func extremum(ctx context.Context, name string, args []ast.Expr, data map[string]interface{}, isMin bool) interface{} {
+	nums, err := evalNumbers(ctx, args, data)
+	if err != nil {
+		return err
+	}
+	if len(nums) == 0 {
+		return fmt.Errorf("func %s expects at least 1 value", name)
+	}
+
+	if !hasFloat(nums) {
+		res := nums[0].i
+		for _, n := range nums[1:] {
+			if (isMin && n.i < res) || (!isMin && n.i > res) {
+				res = n.i
+			}
+		}
+		return res
+	}
+
+	res := nums[0].float()
+	for _, n := range nums[1:] {
+		if isMin {
+			res = math.Min(res, n.float())
+		} else {
+			res = math.Max(res, n.float())
+		}
+	}
+	return res
+}
+
+// mathPow 幂运算：pow(x, y)，返回float64
+func mathPow(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	nums, err := evalNumberArgs(ctx, "pow", args, data, 2, 2)
+	if err != nil {
+		return err
+	}
+	return math.Pow(nums[0].float(), nums[1].float())
+}
+
+// mathSqrt 平方根：sqrt(x)，返回float64
+func mathSqrt(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	nums, err := evalNumberArgs(ctx, "sqrt", args, data, 1, 1)
+	if err != nil {
+		return err
+	}
+	if nums[0].float() < 0 {
+		return fmt.Errorf("func sqrt of negative number: %v", nums[0].value())
+	}
+	return math.Sqrt(nums[0].float())
+}
+
+// mathRound 四舍五入：round(x) 或 round(x, 小数位数)，整数原样返回
+func mathRound(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	nums, err := evalNumberArgs(ctx, "round", args, data, 1, 2)
+	if err != nil {
+		return err
+	}
+	n := nums[0]
+	if !n.isFloat {
+		return n.i
+	}
+	if len(nums) == 1 {
+		return math.Round(n.f)
+	}
+	if nums[1].isFloat {
+		return errors.New("func round 2ed params must be an integer")
+	}
+	scale := math.Pow(10, float64(nums[1].i))
+	return math.Round(n.f*scale) / scale
+}
+
+// mathFloor 向下取整：floor(x)，整数原样返回
+func mathFloor(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	nums, err := evalNumberArgs(ctx, "floor", args, data, 1, 1)
+	if err != nil {
+		return err
+	}
+	if !nums[0].isFloat {
+		return nums[0].i
+	}
+	return math.Floor(nums[0].f)
+}
+
+// mathCeil 向上取整：ceil(x)，整数原样返回
+func mathCeil(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	nums, err := evalNumberArgs(ctx, "ceil", args, data, 1, 1)
+	if err != nil {
+		return err
+	}
+	if !nums[0].isFloat {
+		return nums[0].i
+	}
+	return math.Ceil(nums[0].f)
+}
+
+// mathClamp 区间约束：clamp(x, min, max)
+func mathClamp(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	nums, err := evalNumberArgs(ctx, "clamp", args, data, 3, 3)
+	if err != nil {
+		return err
+	}
+	x, lo, hi := nums[0], nums[1], nums[2]
+	if lo.float() > hi.float() {
+		return fmt.Errorf("func clamp min %v is greater than max %v", lo.value(), hi.value())
+	}
+
+	if !hasFloat(nums) {
+		switch {
+		case x.i < lo.i:
+			return lo.i
+		case x.i > hi.i:
+			return hi.i
+		}
+		return x.i
+	}
+	return math.Min(math.Max(x.float(), lo.float()), hi.float())
+}
+
+// mathSum 求和：sum(x, y, ...)，参数可以为数组
+func mathSum(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	nums, err := evalNumbers(ctx, args, data)
+	if err != nil {
+		return err
+	}
+
+	if !hasFloat(nums) {
+		var total int64
+		for _, n := range nums {
+			res := arithmeticForInt64(total, n.i, token.ADD)
+			if err, ok := res.(error); ok {
+				return err
+			}
+			total = res.(int64)
+		}
+		return total
+	}
+
+	var total float64
+	for _, n := range nums {
+		total += n.float()
+	}
+	return total
+}
+
+// mathAvg 平均值：avg(x, y, ...)，参数可以为数组，返回float64
+func mathAvg(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	nums, err := evalNumbers(ctx, args, data)
+	if err != nil {
+		return err
+	}
+	if len(nums) == 0 {
+		return errors.New("func avg expects at least 1 value")
+	}
+
+	var total float64
+	for _, n := range nums {
+		total += n.float()
+	}
+	return total / float64(len(nums))
+}
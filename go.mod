@@ -1,10 +1,11 @@
 module github.com/BeCrafter/go-parser
 
-go 1.17
+go 1.18
 
 require (
 	github.com/json-iterator/go v1.1.12
 	github.com/pkg/errors v0.9.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -1,15 +1,25 @@
 package goparser
 
 import (
+	"context"
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/scanner"
 	"go/token"
 	"strconv"
+	"strings"
 )
 
-// Match 利用原生parser完成表达式与输入数据匹配任务
+// Match 利用原生parser完成表达式与输入数据匹配任务，表达式的编译结果会被缓存（见 SetMatchCacheSize）
 func Match(expr string, data map[string]interface{}) (bool, error) {
+	return MatchContext(context.Background(), expr, data)
+}
+
+// MatchContext 在上下文中完成表达式与输入数据匹配任务，支持取消、超时及步数限制（见 WithMaxSteps）
+func MatchContext(ctx context.Context, expr string, data map[string]interface{}) (ok bool, err error) {
+	defer recoverPanic(&err, expr)
+
 	// 空表达式默认匹配成功
 	if expr == "" {
 		return true, nil
@@ -18,78 +28,285 @@ func Match(expr string, data map[string]interface{}) (bool, error) {
 	if data == nil {
 		return false, nil
 	}
-	// 解析表达式
-	parseExpr, err := parser.ParseExpr(expr)
+	return observeRule(ctx, expr, expr, func() (bool, error) {
+		// 使用缓存的编译结果。编译失败（如包含不受支持的语法）的错误同样被缓存，
+		// 此时与关闭缓存时一样解析后直接计算，保持计算结果与错误一致
+		if matchCache.enabled() {
+			if p, err := matchCache.Compile(expr); err == nil {
+				return p.match(ctx, data)
+			}
+		}
+
+		// 匹配表达式与输入数据
+		result, err := EvalValueContext(ctx, expr, data)
+		if err != nil {
+			return false, err
+		}
+
+		// 返回匹配结果
+		return resultToBool(expr, result)
+	})
+}
+
+// 可作为函数名使用的Go关键字，如 if(cond, a, b)、default(x, v)
+var keywordFuncs = map[token.Token]string{
+	token.IF:      "if",
+	token.DEFAULT: "default",
+}
+
+// parseExpr 解析表达式字符串
+func parseExpr(expr string) (ast.Expr, error) {
+	parseExpr, _, err := parseExprWithFileSet(expr)
+	return parseExpr, err
+}
+
+// parseExprWithFileSet 解析表达式字符串并返回用于定位节点位置的FileSet。原生parser不接受关键字作为函数名，
+// 解析前先将其替换为等长的大写标识符，解析后再按位置还原函数名
+func parseExprWithFileSet(expr string) (ast.Expr, *token.FileSet, error) {
+	src, renames := rewriteKeywordFuncs(expr)
+
+	fset := token.NewFileSet()
+	parseExpr, err := parser.ParseExprFrom(fset, "", src, 0)
 	if err != nil {
-		return false, err
+		return nil, nil, err
+	}
+	if len(renames) == 0 {
+		return parseExpr, fset, nil
 	}
-	// 表达式中的 true、false 值被识别为变量
-	data["true"] = true
-	data["false"] = false
 
-	// 匹配表达式与输入数据
-	result := Eval(parseExpr, data)
+	ast.Inspect(parseExpr, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := call.Fun.(*ast.Ident); ok {
+			if name, has := renames[fset.Position(ident.Pos()).Offset]; has {
+				ident.Name = name
+			}
+		}
+		return true
+	})
+	return parseExpr, fset, nil
+}
+
+// rewriteKeywordFuncs 替换作为函数调用的关键字，返回替换后的源码及替换位置
+func rewriteKeywordFuncs(expr string) ([]byte, map[int]string) {
+	src := []byte(expr)
+
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	s.Init(file, src, nil, 0)
+
+	var out []byte
+	var renames map[int]string
+	prevOffset, prevName := -1, ""
+	for {
+		pos, tok, _ := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.LPAREN && prevOffset >= 0 {
+			if out == nil {
+				out = append([]byte(nil), src...)
+				renames = make(map[int]string)
+			}
+			copy(out[prevOffset:], strings.ToUpper(prevName))
+			renames[prevOffset] = prevName
+		}
 
-	// 返回匹配结果
-	if _, ok := result.(error); ok {
-		return false, result.(error)
+		prevOffset, prevName = -1, ""
+		if name, ok := keywordFuncs[tok]; ok {
+			prevOffset, prevName = file.Offset(pos), name
+		}
+	}
+	if out == nil {
+		return src, nil
 	}
-	return result.(bool), nil
+	return out, renames
 }
 
+// Eval 计算表达式语法树，返回计算结果或error
 func Eval(expr ast.Expr, data map[string]interface{}) interface{} {
+	return EvalContext(context.Background(), expr, data)
+}
+
+// EvalContext 在上下文中计算表达式语法树，上下文取消、超时或超出步数限制时返回对应的error
+func EvalContext(ctx context.Context, expr ast.Expr, data map[string]interface{}) interface{} {
+	state := stateFrom(ctx)
+	if err := visitNode(ctx, state); err != nil {
+		return err
+	}
+
+	// 公共子表达式在单次计算中只计算一次
+	if state != nil && state.shared != nil {
+		if key, ok := state.shared[expr]; ok {
+			if v, hit := state.cache[key]; hit {
+				return v
+			}
+			v := evalNode(ctx, expr, data)
+			state.cache[key] = v
+			return v
+		}
+	}
+	return evalNode(ctx, expr, data)
+}
+
+// evalNode 按照节点类型计算表达式
+func evalNode(ctx context.Context, expr ast.Expr, data map[string]interface{}) interface{} {
 	switch expr := expr.(type) {
 	case *ast.BasicLit: // 匹配到数据
 		return getlitValue(expr)
 	case *ast.BinaryExpr: // 匹配到子树
-		x := Eval(expr.X, data)
-		y := Eval(expr.Y, data)
-		if x == nil || y == nil {
-			return fmt.Errorf("%+v, %+v is nil", x, y)
-		}
-		op := expr.Op
-		// 规则计算（按照规则表达式中变量的类型进行匹配）
-		switch y.(type) {
-		case int:
-			return calculateForInt(x, y, op)
-		case int64:
-			return calculateForInt64(x, y, op)
-		case string:
-			return calculateForString(x, y, op)
-		case bool:
-			return calculateForBool(x, y, op)
-		case error:
-			return fmt.Errorf("%+v %+v %+v eval failed", x, op, y)
-		default:
-			return fmt.Errorf("%+v op is not support", op)
+		x := EvalContext(ctx, expr.X, data)
+		if isAbort(x) {
+			return x
+		}
+		if b, ok := shortCircuit(x, expr.Op); ok {
+			return b
+		}
+		y := EvalContext(ctx, expr.Y, data)
+		if isAbort(y) {
+			return y
 		}
+		return evalBinary(x, y, expr.Op)
 	case *ast.CallExpr: // 匹配到函数
-		return calculateForFunc(expr.Fun.(*ast.Ident).Name, expr.Args, data)
+		funcName, ok := selectorName(expr.Fun)
+		// 未注册为命名空间函数的选择器按值方法调用处理，如 name.HasPrefix("a")
+		if sel, isSel := expr.Fun.(*ast.SelectorExpr); isSel {
+			if _, has := funcNameMap[funcName]; !ok || !has {
+				return calculateForMethod(ctx, sel, expr.Args, data)
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%T callee is not support", expr.Fun)
+		}
+		return calculateForFunc(ctx, funcName, expr.Args, data)
+	case *ast.SelectorExpr: // 匹配到命名空间常量，如 time.Hour
+		name, _ := selectorName(expr)
+		if v, ok := constNameMap[name]; ok {
+			return v
+		}
+		return fmt.Errorf("%s is not support", name)
 	case *ast.ParenExpr: // 匹配到括号
-		return Eval(expr.X, data)
+		return EvalContext(ctx, expr.X, data)
 	case *ast.UnaryExpr: // 匹配到一元表达式
-		x := Eval(expr.X, data)
-		if x == nil {
-			return fmt.Errorf("%+v is nil", x)
-		}
-		op := expr.Op
-		switch op {
-		case token.NOT:
-			switch x.(type) {
-			case bool:
-				xb := x.(bool)
-				return !xb
+		x := EvalContext(ctx, expr.X, data)
+		if isAbort(x) {
+			return x
+		}
+		return evalUnary(expr, x)
+	case *ast.CompositeLit: // 匹配到数组字面量，如 []int{1, 2, 3}
+		elts := make([]interface{}, 0, len(expr.Elts))
+		for _, elt := range expr.Elts {
+			v := EvalContext(ctx, elt, data)
+			if err, ok := v.(error); ok {
+				return err
 			}
+			elts = append(elts, v)
 		}
-		return fmt.Errorf("%x type is not support", expr)
+		return elts
 	case *ast.Ident: // 匹配到变量
-		return data[expr.Name]
+		// 表达式中的 true、false 值被识别为变量
+		switch expr.Name {
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		return lookupVar(ctx, data, expr.Name)
 	default:
 		return fmt.Errorf("%x type is not support", expr)
 	}
 }
 
-// 获取AST中变量的数据（表达式中的数字为int，转为int64）
+// evalBinary 计算二元表达式，按照右侧操作数的类型选择运算方式；数值运算中任一操作数为浮点数时按float64计算
+func evalBinary(x, y interface{}, op token.Token) interface{} {
+	if x == nil || y == nil {
+		return fmt.Errorf("%+v, %+v is nil", x, y)
+	}
+	// 左侧子表达式计算出错时直接向上传递
+	if err, ok := x.(error); ok {
+		return err
+	}
+	// 规则计算（按照规则表达式中变量的类型进行匹配）
+	switch y.(type) {
+	case int:
+		if isFloat(x) {
+			return calculateForFloat64(x, y, op)
+		}
+		return calculateForInt(x, y, op)
+	case int64:
+		if isFloat(x) {
+			return calculateForFloat64(x, y, op)
+		}
+		return calculateForInt64(x, y, op)
+	case float32, float64:
+		return calculateForFloat64(x, y, op)
+	case string:
+		return calculateForString(x, y, op)
+	case bool:
+		return calculateForBool(x, y, op)
+	case error:
+		return fmt.Errorf("%+v %+v %w eval failed", x, op, y.(error))
+	default:
+		return fmt.Errorf("%+v op is not support", op)
+	}
+}
+
+// shortCircuit && 左侧为false或 || 左侧为true时结果已确定，右侧不再计算，返回确定的结果
+func shortCircuit(x interface{}, op token.Token) (bool, bool) {
+	b, ok := x.(bool)
+	if ok && ((op == token.LAND && !b) || (op == token.LOR && b)) {
+		return b, true
+	}
+	return false, false
+}
+
+// evalUnary 计算一元表达式
+func evalUnary(expr *ast.UnaryExpr, x interface{}) interface{} {
+	if x == nil {
+		return fmt.Errorf("%+v is nil", x)
+	}
+	if err, ok := x.(error); ok {
+		return err
+	}
+	op := expr.Op
+	switch op {
+	case token.NOT:
+		switch x.(type) {
+		case bool:
+			xb := x.(bool)
+			return !xb
+		}
+	case token.SUB, token.ADD:
+		switch x.(type) {
+		case float32, float64:
+			return calculateForUnaryFloat64(x, op)
+		}
+		return calculateForUnaryInt64(x, op)
+	case token.XOR:
+		return calculateForUnaryInt64(x, op)
+	}
+	return fmt.Errorf("%x type is not support", expr)
+}
+
+// selectorName 获取标识符或以 "." 分隔的选择器全名，如 strings.HasPrefix
+func selectorName(expr ast.Expr) (string, bool) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name, true
+	case *ast.SelectorExpr:
+		x, ok := selectorName(expr.X)
+		if !ok {
+			return "", false
+		}
+		return x + "." + expr.Sel.Name, true
+	}
+	return "", false
+}
+
+// 获取AST中变量的数据（表达式中的整数为int，转为int64；小数转为float64）
 func getlitValue(basicLit *ast.BasicLit) interface{} {
 	switch basicLit.Kind {
 	case token.INT:
@@ -98,6 +315,12 @@ func getlitValue(basicLit *ast.BasicLit) interface{} {
 			return err
 		}
 		return value
+	case token.FLOAT:
+		value, err := strconv.ParseFloat(basicLit.Value, 64)
+		if err != nil {
+			return err
+		}
+		return value
 	case token.STRING:
 		value, err := strconv.Unquote(basicLit.Value)
 		if err != nil {
@@ -1,10 +1,21 @@
 package goparser
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"math"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestGoParser_Match(t *testing.T) {
@@ -151,6 +162,44 @@ func BenchmarkGoParser_Match(b *testing.B) {
 	}
 }
 
+func BenchmarkGoParser_Program(b *testing.B) {
+	data := map[string]interface{}{
+		"a": 1,
+		"b": "b",
+		"c": 100,
+		"d": true,
+	}
+	benchmarks := []struct {
+		name string
+		expr string
+	}{
+		{name: "typed", expr: `(a == 1 && b == "b" && c >= 98) || (d == false)`},
+		{name: "in_array", expr: `(a == 1 && b == "b" && in_array(c, []int{100,99,98,97})) || (d == false)`},
+	}
+	for _, bm := range benchmarks {
+		p, err := Compile(bm.expr)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(bm.name+"/vm", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := p.Match(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(bm.name+"/ast", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := resultToBool(bm.expr, Eval(p.root, data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 func TestGoParser_Expression(t *testing.T) {
 	tests := []string{
 		`{
@@ -910,3 +959,1816 @@ func TestGoParser_RunAll(t *testing.T) {
 		})
 	}
 }
+
+func TestGoParser_Arithmetic(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		data    map[string]interface{}
+		want    bool
+		wantErr error
+	}{
+		{
+			name: "test_case1",
+			expr: "a % 3 == 1 && a / 3 == 3",
+			data: map[string]interface{}{"a": 10},
+			want: true,
+		},
+		{
+			name: "test_case2",
+			expr: "a % 10 == 7",
+			data: map[string]interface{}{"a": int64(9007199254740997)},
+			want: true,
+		},
+		{
+			name:    "test_case3",
+			expr:    "a / b == 0",
+			data:    map[string]interface{}{"a": 1, "b": 0},
+			wantErr: ErrDivisionByZero,
+		},
+		{
+			name:    "test_case4",
+			expr:    "a % b == 0",
+			data:    map[string]interface{}{"a": 1, "b": 0},
+			wantErr: ErrDivisionByZero,
+		},
+		{
+			name:    "test_case5",
+			expr:    "a + 1 > 0",
+			data:    map[string]interface{}{"a": int64(9223372036854775807)},
+			wantErr: ErrIntegerOverflow,
+		},
+		{
+			name:    "test_case6",
+			expr:    "a * 2 > 0",
+			data:    map[string]interface{}{"a": int64(-9223372036854775808)},
+			wantErr: ErrIntegerOverflow,
+		},
+		{
+			name: "test_case7",
+			expr: "a & 6 == 2 && a | 1 == 3 && a ^ 3 == 1 && a &^ 2 == 0 && a << 2 == 8 && a >> 1 == 1",
+			data: map[string]interface{}{"a": 2},
+			want: true,
+		},
+		{
+			name: "test_case8",
+			expr: "-a == -5 && ^a == -6",
+			data: map[string]interface{}{"a": 5},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Match(tt.expr, tt.data)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("goParser arithmetic failed, wantErr=%v, err=%v", tt.wantErr, err)
+				}
+				return
+			}
+			if got != tt.want || err != nil {
+				t.Errorf("goParser arithmetic failed, want=%v, got=%v, err=%v", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestGoParser_MixedArithmetic(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		data    map[string]interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "gtr_float_left", expr: "price > 10", data: map[string]interface{}{"price": 10.5}, want: true},
+		{name: "eql_float_left", expr: "price == 10", data: map[string]interface{}{"price": 10.5}, want: false},
+		{name: "add_float_left", expr: "1.5 + 1", want: 2.5},
+		{name: "add_float_right", expr: "1 + 1.5", want: 2.5},
+		{name: "mul_float_left", expr: "f * 2", data: map[string]interface{}{"f": 2.5}, want: float64(5)},
+		{name: "mul_float_right", expr: "2 * f", data: map[string]interface{}{"f": 2.5}, want: float64(5)},
+		{name: "mul_float32", expr: "f * a", data: map[string]interface{}{"f": float32(0.5), "a": int64(3)}, want: 1.5},
+		{name: "quo_float_left", expr: "f / 2", data: map[string]interface{}{"f": 5.0}, want: 2.5},
+		{name: "func_float_left", expr: "sqrt(a) > 2", data: map[string]interface{}{"a": 8}, want: true},
+		{name: "int_int", expr: "7 / 2", want: int64(3)},
+		{name: "rem_float", expr: "7.5 % 2", wantErr: true},
+		{name: "rem_float_var", expr: "f % 2 == 1", data: map[string]interface{}{"f": 7.5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvalValue(tt.expr, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("goParser mixed arithmetic failed, wantErr=%v, err=%v", tt.wantErr, err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("goParser mixed arithmetic failed, want=%v(%T), got=%v(%T)", tt.want, tt.want, got, got)
+			}
+		})
+	}
+}
+
+func TestGoParser_MathFuncs(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		data    map[string]interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "abs_int", expr: "abs(a)", data: map[string]interface{}{"a": -3}, want: int64(3)},
+		{name: "abs_float", expr: "abs(-1.5)", want: 1.5},
+		{name: "min_int", expr: "min(a, 2, 7)", data: map[string]interface{}{"a": 5}, want: int64(2)},
+		{name: "min_mixed", expr: "min(a, 2, 1.5)", data: map[string]interface{}{"a": 5}, want: 1.5},
+		{name: "max_slice", expr: "max(scores, 3)", data: map[string]interface{}{"scores": []int{1, 9, 4}}, want: int64(9)},
+		{name: "max_literal", expr: "max([]int{1, 9, 4})", want: int64(9)},
+		{name: "max_empty", expr: "max(scores)", data: map[string]interface{}{"scores": []int{}}, wantErr: true},
+		{name: "pow", expr: "pow(2, 10)", want: float64(1024)},
+		{name: "sqrt", expr: "sqrt(16)", want: float64(4)},
+		{name: "sqrt_negative", expr: "sqrt(-1)", wantErr: true},
+		{name: "round", expr: "round(2.5)", want: float64(3)},
+		{name: "round_places", expr: "round(3.14159, 2)", want: 3.14},
+		{name: "round_int", expr: "round(7)", want: int64(7)},
+		{name: "floor", expr: "floor(2.7)", want: float64(2)},
+		{name: "ceil", expr: "ceil(2.1)", want: float64(3)},
+		{name: "clamp_int", expr: "clamp(a, 0, 10)", data: map[string]interface{}{"a": 15}, want: int64(10)},
+		{name: "clamp_float", expr: "clamp(-0.5, 0, 1)", want: float64(0)},
+		{name: "clamp_invalid", expr: "clamp(1, 10, 0)", wantErr: true},
+		{name: "sum", expr: "sum(scores, 10)", data: map[string]interface{}{"scores": []int64{1, 2, 3}}, want: int64(16)},
+		{name: "sum_float", expr: "sum(scores)", data: map[string]interface{}{"scores": []interface{}{1, 0.5}}, want: 1.5},
+		{name: "avg", expr: "avg(scores)", data: map[string]interface{}{"scores": []int{1, 2, 3, 4}}, want: 2.5},
+		{name: "arity", expr: "pow(2)", wantErr: true},
+		{name: "not_number", expr: "abs(a)", data: map[string]interface{}{"a": true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseExpr, err := parser.ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("parse expr failed, err=%v", err)
+			}
+			got := Eval(parseExpr, tt.data)
+			if _, isErr := got.(error); isErr != tt.wantErr {
+				t.Fatalf("goParser math func failed, wantErr=%v, got=%v", tt.wantErr, got)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("goParser math func failed, want=%v(%T), got=%v(%T)", tt.want, tt.want, got, got)
+			}
+		})
+	}
+
+	// 数学函数与比较运算组合使用
+	if got, err := Match("max(a, b) >= 10 && sqrt(c) > 2.5", map[string]interface{}{"a": 3, "b": 10, "c": 9}); !got || err != nil {
+		t.Errorf("goParser math func match failed, got=%v, err=%v", got, err)
+	}
+}
+
+func TestGoParser_CondFuncs(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		data    map[string]interface{}
+		want    bool
+		wantErr bool
+	}{
+		{
+			name: "if_true",
+			expr: "if(vip, 10, 0) == 10",
+			data: map[string]interface{}{"vip": true},
+			want: true,
+		},
+		{
+			name: "if_false",
+			expr: "if(vip, 10, 0) == 0",
+			data: map[string]interface{}{"vip": false},
+			want: true,
+		},
+		{
+			name: "if_lazy",
+			expr: "if(b != 0, a / b, 0) == 0",
+			data: map[string]interface{}{"a": 1, "b": 0},
+			want: true,
+		},
+		{
+			name: "if_nested",
+			expr: "if(age >= 18, if(age >= 60, \"senior\", \"adult\"), \"minor\") == \"adult\"",
+			data: map[string]interface{}{"age": 30},
+			want: true,
+		},
+		{
+			name:    "if_arity",
+			expr:    "if(vip, 10) == 10",
+			data:    map[string]interface{}{"vip": true},
+			wantErr: true,
+		},
+		{
+			name: "coalesce",
+			expr: "coalesce(nickname, name, \"guest\") == \"tom\"",
+			data: map[string]interface{}{"name": "tom"},
+			want: true,
+		},
+		{
+			name: "default_missing",
+			expr: "default(score, 60) >= 60",
+			data: map[string]interface{}{},
+			want: true,
+		},
+		{
+			name: "default_present",
+			expr: "default(score, 60) >= 60",
+			data: map[string]interface{}{"score": 59},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Match(tt.expr, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("goParser cond func failed, wantErr=%v, err=%v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("goParser cond func failed, want=%v, got=%v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGoParser_EvalTyped(t *testing.T) {
+	data := map[string]interface{}{
+		"price": 100,
+		"rate":  0.8,
+		"name":  "tom",
+		"vip":   true,
+	}
+
+	if got, err := EvalInt("price * 2 + 1", data); got != 201 || err != nil {
+		t.Errorf("goParser EvalInt failed, got=%v, err=%v", got, err)
+	}
+	if got, err := EvalInt("round(price * rate)", data); got != 80 || err != nil {
+		t.Errorf("goParser EvalInt failed, got=%v, err=%v", got, err)
+	}
+	if got, err := EvalFloat("price * rate", data); got != 80 || err != nil {
+		t.Errorf("goParser EvalFloat failed, got=%v, err=%v", got, err)
+	}
+	if got, err := EvalFloat("price + 1", data); got != 101 || err != nil {
+		t.Errorf("goParser EvalFloat failed, got=%v, err=%v", got, err)
+	}
+	if got, err := EvalString("if(vip, name, \"guest\")", data); got != "tom" || err != nil {
+		t.Errorf("goParser EvalString failed, got=%v, err=%v", got, err)
+	}
+	if got, err := EvalBool("vip && price > 50", data); !got || err != nil {
+		t.Errorf("goParser EvalBool failed, got=%v, err=%v", got, err)
+	}
+	if got, err := EvalValue("min(price, 10)", data); got != int64(10) || err != nil {
+		t.Errorf("goParser EvalValue failed, got=%v, err=%v", got, err)
+	}
+	if got, err := EvalValue("1 + 2", nil); got != int64(3) || err != nil {
+		t.Errorf("goParser EvalValue failed, got=%v, err=%v", got, err)
+	}
+
+	// 结果类型不匹配时返回 ResultTypeError
+	var typeErr *ResultTypeError
+	if _, err := EvalInt("price * rate + 0.5", data); !errors.As(err, &typeErr) || typeErr.Want != ResultInt {
+		t.Errorf("goParser EvalInt want ResultTypeError, err=%v", err)
+	}
+	if _, err := EvalString("price", data); !errors.As(err, &typeErr) || typeErr.Want != ResultString {
+		t.Errorf("goParser EvalString want ResultTypeError, err=%v", err)
+	}
+	if _, err := Match("price + 1", data); !errors.As(err, &typeErr) || typeErr.Want != ResultBool {
+		t.Errorf("goParser Match want ResultTypeError, err=%v", err)
+	}
+	if _, err := EvalValue("", data); err == nil {
+		t.Errorf("goParser EvalValue want error for empty expression")
+	}
+
+	// Match 不再向输入数据中写入 true、false
+	if _, ok := data["true"]; ok {
+		t.Errorf("goParser Match should not modify input data")
+	}
+}
+
+func TestGoParser_PanicSafe(t *testing.T) {
+	// 自定义函数中的panic被转换为错误
+	RegisterFunc("test_panic", func(args []ast.Expr, data map[string]interface{}) interface{} {
+		panic("boom")
+	})
+	defer delete(funcNameMap, "test_panic")
+
+	var panicErr *PanicError
+	if _, err := Match("test_panic() == 1", map[string]interface{}{}); !errors.As(err, &panicErr) {
+		t.Errorf("goParser Match want PanicError, err=%v", err)
+	}
+
+	tests := []string{
+		`unknown.Contains(a, "x")`,
+		`in_array(a)`,
+		`in_array()`,
+		`a + 1`,
+		`a[0] == 1`,
+		`func() {}`,
+		`x.(int) == 1`,
+	}
+	for _, expr := range tests {
+		if _, err := Match(expr, map[string]interface{}{"a": "xyz"}); err == nil {
+			t.Errorf("goParser Match %q want error", expr)
+		}
+	}
+
+	if InArray(1, 1) {
+		t.Errorf("goParser InArray with non-collection haystack want false")
+	}
+	if _, err := Expression(map[string]interface{}{"connector": 1, "children": []interface{}{}}); err != nil {
+		t.Errorf("goParser Expression with invalid connector failed, err=%v", err)
+	}
+}
+
+func FuzzMatch(f *testing.F) {
+	seeds := []string{
+		"a == 1 && b == 2",
+		"!(a == 1 && b == 2) || (c == \"test\" && d == false)",
+		`(a == 1 && b == "b" && in_array(c, []int{100,99,98,97})) || (d == false)`,
+		"if(d, a / b, sqrt(c)) > 1.5",
+		"max(a, b, c) % 3 == coalesce(e, 0)",
+		"a << 70 == -a",
+		`strings.HasPrefix(b, "x")`,
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, expr string) {
+		data := map[string]interface{}{
+			"a": 1,
+			"b": "b",
+			"c": int64(100),
+			"d": true,
+		}
+		_, _ = Match(expr, data)
+		_, _ = EvalValue(expr, data)
+	})
+}
+
+func FuzzExpression(f *testing.F) {
+	f.Add(`{"connector": "AND", "children": [{"op": "GE", "value": 43, "field": "age"}, {"op": "EQ", "value": "haha", "field": "name"}]}`)
+	f.Add(`{"connector": "NOT", "children": [{"connector": "OR", "children": [{"op": "REM", "value": 2, "field": "a"}]}]}`)
+	f.Fuzz(func(t *testing.T, str string) {
+		var tmp map[string]interface{}
+		if err := json.Unmarshal([]byte(str), &tmp); err != nil {
+			return
+		}
+		if exp, err := Expression(tmp); err == nil {
+			_, _ = Match(exp, map[string]interface{}{})
+		}
+		_, _ = ExportFields(tmp)
+	})
+}
+
+func TestGoParser_NamespaceFuncs(t *testing.T) {
+	RegisterStdlib()
+
+	// 自定义命名空间函数
+	RegisterFuncContext("geo.InCircle", func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		values, err := evalArgs(ctx, "geo.InCircle", args, data, 3)
+		if err != nil {
+			return err
+		}
+		x, _ := castToFloat64(values[0])
+		y, _ := castToFloat64(values[1])
+		r, _ := castToFloat64(values[2])
+		return x*x+y*y <= r*r
+	})
+	defer delete(funcNameMap, "geo.InCircle")
+
+	data := map[string]interface{}{
+		"name":    "alice",
+		"email":   "Alice@Example.com",
+		"x":       3,
+		"y":       4,
+		"created": time.Now().Add(-48 * time.Hour),
+		"expire":  time.Now().Add(time.Hour).Format(time.RFC3339),
+	}
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{name: "strings", expr: `strings.HasPrefix(name, "a") && !strings.Contains(name, "z")`, want: true},
+		{name: "strings_lower", expr: `strings.HasSuffix(strings.ToLower(email), "@example.com")`, want: true},
+		{name: "strings_index", expr: `strings.Index(name, "ice") == 2`, want: true},
+		{name: "math", expr: `math.Sqrt(x * x + y * y) == 5.0 && math.Pi > 3.14`, want: true},
+		{name: "time_since", expr: `time.Since(created) > 24 * time.Hour`, want: true},
+		{name: "time_until", expr: `time.Until(expire) < time.Hour`, want: true},
+		{name: "time_parse", expr: `time.ParseDuration("1h30m") == 90 * time.Minute`, want: true},
+		{name: "custom", expr: `geo.InCircle(x, y, 5)`, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Match(tt.expr, data); got != tt.want || err != nil {
+				t.Errorf("goParser namespace func failed, want=%v, got=%v, err=%v", tt.want, got, err)
+			}
+		})
+	}
+
+	if _, err := Match(`unknown.Func(name)`, data); err == nil {
+		t.Errorf("goParser unregistered namespace func want error")
+	}
+}
+
+type testUser struct {
+	Name  string
+	Level int
+}
+
+func (u testUser) IsVip(minLevel int) bool {
+	return u.Level >= minLevel
+}
+
+func (u testUser) Greeting() (string, error) {
+	if u.Name == "" {
+		return "", errors.New("empty name")
+	}
+	return "hi " + u.Name, nil
+}
+
+func (u testUser) Secret() string {
+	return "secret"
+}
+
+func TestGoParser_Methods(t *testing.T) {
+	AllowMethods(testUser{}, "IsVip", "Greeting")
+
+	data := map[string]interface{}{
+		"name":  "alice",
+		"tags":  []string{"a", "b"},
+		"ids":   []int{1, 2, 3},
+		"attrs": map[string]interface{}{"k": 1},
+		"user":  testUser{Name: "bob", Level: 3},
+		"anon":  testUser{},
+	}
+	tests := []struct {
+		name    string
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "string", expr: `name.HasPrefix("a") && name.Len() == 5`, want: true},
+		{name: "string_chain", expr: `name.ToUpper().Split("L").Len() == 2`, want: true},
+		{name: "string_literal", expr: `"abc".Contains("b")`, want: true},
+		{name: "list", expr: `tags.Len() == 2 && tags.Contains("b") && ids.Contains(3)`, want: true},
+		{name: "map", expr: `attrs.Has("k") && !attrs.Has("x")`, want: true},
+		{name: "struct", expr: `user.IsVip(3) && user.Greeting() == "hi bob"`, want: true},
+		{name: "struct_error", expr: `anon.Greeting() == ""`, wantErr: true},
+		{name: "struct_not_allowed", expr: `user.Secret() == "secret"`, wantErr: true},
+		{name: "unknown_method", expr: `name.Foo()`, wantErr: true},
+		{name: "nil_receiver", expr: `missing.Len() == 0`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Match(tt.expr, data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("goParser method failed, wantErr=%v, err=%v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("goParser method failed, want=%v, got=%v", tt.want, got)
+			}
+		})
+	}
+
+	// 未定义的标识符作为接收者时按未注册的命名空间函数报错，已定义为nil的变量按值方法报错
+	for expr, want := range map[string]string{
+		`strings.HasPrefixx(name, "a")`: "unknown function strings.HasPrefixx",
+		`missing.Len() == 0`:            "unknown function missing.Len",
+		`empty.Len() == 0`:              "method Len called on nil value",
+	} {
+		if _, err := Match(expr, map[string]interface{}{"name": "alice", "empty": nil}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("goParser method %s err=%v, want %q", expr, err, want)
+		}
+	}
+}
+
+func TestGoParser_MatchContext(t *testing.T) {
+	// 函数需在计算开始前注册
+	block := make(chan struct{})
+	defer close(block)
+	RegisterFunc("test_block", func(args []ast.Expr, data map[string]interface{}) interface{} {
+		<-block
+		return true
+	})
+	RegisterFuncContext("test_ctx", func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		return stateFrom(ctx) != nil
+	})
+
+	data := map[string]interface{}{"a": 1, "b": 2}
+
+	// 步数限制
+	if got, err := MatchContext(WithMaxSteps(context.Background(), 100), "a == 1 && b == 2", data); !got || err != nil {
+		t.Errorf("goParser MatchContext failed, got=%v, err=%v", got, err)
+	}
+	if _, err := MatchContext(WithMaxSteps(context.Background(), 3), "a == 1 && b == 2", data); !errors.Is(err, ErrStepLimitExceeded) {
+		t.Errorf("goParser MatchContext want ErrStepLimitExceeded, err=%v", err)
+	}
+	// 超出步数限制后立即终止计算，错误不会被外层节点重复包装
+	for expr, max := range map[string]int64{
+		`(a == 1) && (b == 2)`:                   8,
+		`a == 1 && b == 2 && a + b == 3`:         9,
+		`in_array(a, []int{1, 2, 3}) && b == 2`:  8,
+		`!(a == 2) && max(a, b, a, b) > 1`:       9,
+		`if(a == 1, b + 1, 2) > 1 || a + b == 3`: 7,
+	} {
+		for _, size := range []int{DefaultMatchCacheSize, 0} {
+			SetMatchCacheSize(size)
+			_, err := MatchContext(WithMaxSteps(context.Background(), max), expr, data)
+			if want := fmt.Sprintf("step limit exceeded: max %d", max); err == nil || err.Error() != want {
+				t.Errorf("goParser MatchContext %s cache size %d err=%v, want %q", expr, size, err, want)
+			}
+		}
+	}
+	SetMatchCacheSize(DefaultMatchCacheSize)
+	// 内置函数继承步数限制
+	if _, err := MatchContext(WithMaxSteps(context.Background(), 5), "max(a, b, a, b, a, b) > 1", data); !errors.Is(err, ErrStepLimitExceeded) {
+		t.Errorf("goParser MatchContext want ErrStepLimitExceeded in func args, err=%v", err)
+	}
+	// 支持上下文的自定义函数
+	if got, err := MatchContext(WithMaxSteps(context.Background(), 10), "test_ctx()", data); !got || err != nil {
+		t.Errorf("goParser MatchContext func ctx failed, got=%v, err=%v", got, err)
+	}
+
+	// 已取消的上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := MatchContext(ctx, "a == 1", data); !errors.Is(err, context.Canceled) {
+		t.Errorf("goParser MatchContext want context.Canceled, err=%v", err)
+	}
+
+	// 阻塞的自定义函数不会阻塞调用方
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := MatchContext(ctx, "test_block()", data); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("goParser MatchContext want context.DeadlineExceeded, err=%v", err)
+	}
+}
+
+func TestGoParser_Compile(t *testing.T) {
+	expr := `a == 1 && in_array(b, []int{1, 2, 3}) && name.HasPrefix("x")`
+	tests := []struct {
+		name   string
+		opts   []CompileOption
+		policy string
+	}{
+		{name: "no_limit"},
+		{name: "length", opts: []CompileOption{LimitLength(10)}, policy: PolicyMaxLength},
+		{name: "depth_ok", opts: []CompileOption{LimitDepth(5)}},
+		{name: "depth", opts: []CompileOption{LimitDepth(3)}, policy: PolicyMaxDepth},
+		{name: "nodes", opts: []CompileOption{LimitNodes(5)}, policy: PolicyMaxNodes},
+		{name: "list_size", opts: []CompileOption{LimitListSize(2)}, policy: PolicyMaxListSize},
+		{name: "allow_funcs", opts: []CompileOption{AllowFuncs("in_array", ".HasPrefix")}},
+		{name: "allow_funcs_missing", opts: []CompileOption{AllowFuncs("in_array")}, policy: PolicyFunc},
+		{name: "deny_funcs", opts: []CompileOption{DenyFuncs("in_array")}, policy: PolicyFunc},
+		{name: "allow_vars", opts: []CompileOption{AllowVars("a", "b", "name")}},
+		{name: "allow_vars_missing", opts: []CompileOption{AllowVars("a", "b")}, policy: PolicyVar},
+		{name: "deny_vars", opts: []CompileOption{DenyVars("name")}, policy: PolicyVar},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Compile(expr, tt.opts...)
+			if tt.policy == "" {
+				if err != nil {
+					t.Fatalf("goParser compile failed, err=%v", err)
+				}
+				got, err := p.Match(map[string]interface{}{"a": 1, "b": 2, "name": "xyz"})
+				if !got || err != nil {
+					t.Errorf("goParser program match failed, got=%v, err=%v", got, err)
+				}
+				return
+			}
+			var policyErr *PolicyError
+			if !errors.Is(err, ErrPolicyViolation) || !errors.As(err, &policyErr) || policyErr.Policy != tt.policy {
+				t.Errorf("goParser compile want policy %s violation, err=%v", tt.policy, err)
+			}
+		})
+	}
+
+	if _, err := Compile(""); err == nil {
+		t.Errorf("goParser compile empty expression want error")
+	}
+	if _, err := Compile("a ==="); err == nil {
+		t.Errorf("goParser compile invalid expression want error")
+	}
+}
+
+func TestGoParser_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		issues []string
+	}{
+		{name: "valid", expr: `a == 1 && in_array(b, []int{1, 2}) && -c < 2.5 && name.HasPrefix("x") && if(d, 1, 2) > 0`},
+		{name: "valid_nested_list", expr: `max([][]int{{1, 2}, {3}}) > 0`},
+		{name: "func_lit", expr: `func() bool { return true }()`, issues: []string{"1:1: call of func literal"}},
+		{name: "type_assert", expr: `a.(int) == 1`, issues: []string{"1:1: type assertion"}},
+		{name: "index_slice", expr: `a[0] == b[1:2]`, issues: []string{"1:1: index expression", "1:9: slice expression"}},
+		{name: "star", expr: `*a == 1`, issues: []string{"1:1: pointer expression"}},
+		{name: "chan", expr: `<-ch == 1 && &a != nil`, issues: []string{"1:1: unary operator <-", "1:14: unary operator &"}},
+		{name: "composite", expr: `in_array(a, map[string]int{"x": 1}) && struct{}{} == b`, issues: []string{"1:13: composite literal of map type", "1:28: keyed element in composite literal", "1:40: composite literal of struct type"}},
+		{name: "char", expr: `a == 'x'`, issues: []string{"1:6: char literal 'x'"}},
+		{name: "variadic", expr: `max(a...) > 1`, issues: []string{"1:1: variadic call with ..."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.expr)
+			if len(tt.issues) == 0 {
+				if err != nil {
+					t.Errorf("goParser validate failed, err=%v", err)
+				}
+				return
+			}
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("goParser validate want SyntaxError, err=%v", err)
+			}
+			if got := ErrorIssues(err); !reflect.DeepEqual(got, tt.issues) {
+				t.Errorf("goParser validate failed, want=%v, got=%v", tt.issues, got)
+			}
+		})
+	}
+
+	if _, err := Compile(`a[0] == 1`); err == nil {
+		t.Errorf("goParser compile want SyntaxError")
+	}
+	if got := ErrorIssues(Validate("a +")); !reflect.DeepEqual(got, []string{"1:4: expected operand, found 'EOF'"}) {
+		t.Errorf("goParser error issues of parse error got=%v", got)
+	}
+	if got := ErrorIssues(errors.New("x")); !reflect.DeepEqual(got, []string{"x"}) {
+		t.Errorf("goParser error issues of plain error got=%v", got)
+	}
+}
+
+func TestGoParser_NormalizeJSON(t *testing.T) {
+	dec := json.NewDecoder(strings.NewReader(`{"a": 1, "b": 0.5, "c": [2, {"d": 3e2}], "e": "x", "f": 1e30}`))
+	dec.UseNumber()
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"a": int64(1), "b": 0.5, "c": []interface{}{int64(2), map[string]interface{}{"d": float64(300)}}, "e": "x", "f": 1e30,
+	}
+	if got := NormalizeJSON(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("goParser normalize json got=%#v", got)
+	}
+}
+
+func TestGoParser_RuleSet(t *testing.T) {
+	var calls int
+	RegisterFuncContext("test_rs_score", func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		calls++
+		return Eval(args[0], data)
+	})
+
+	rs := NewRuleSet()
+	rules := []struct {
+		id       string
+		priority int
+		expr     string
+	}{
+		{id: "adult", priority: 1, expr: `test_rs_score(age) >= 18`},
+		{id: "vip", priority: 10, expr: `test_rs_score(age) >= 18 && level > 3`},
+		{id: "beijing", priority: 5, expr: `city == "beijing"`},
+		{id: "broken", priority: 100, expr: `missing.Len() > 0`},
+	}
+	for _, r := range rules {
+		if err := rs.Add(r.id, r.priority, r.expr); err != nil {
+			t.Fatalf("goParser ruleset add %s failed, err=%v", r.id, err)
+		}
+	}
+	if err := rs.Add("adult", 0, "a == 1"); err == nil {
+		t.Errorf("goParser ruleset add duplicate id want error")
+	}
+	if err := rs.Add("invalid", 0, "a ==="); err == nil {
+		t.Errorf("goParser ruleset add invalid expression want error")
+	}
+	if rs.Len() != len(rules) {
+		t.Errorf("goParser ruleset len=%d, want %d", rs.Len(), len(rules))
+	}
+
+	ids := func(rules []*Rule) []string {
+		var ids []string
+		for _, r := range rules {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+	id := func(r *Rule) string {
+		if r == nil {
+			return ""
+		}
+		return r.ID
+	}
+	tests := []struct {
+		name  string
+		data  map[string]interface{}
+		all   []string
+		first string
+		best  string
+	}{
+		{name: "vip_beijing", data: map[string]interface{}{"age": 20, "level": 5, "city": "beijing"}, all: []string{"adult", "vip", "beijing"}, first: "adult", best: "vip"},
+		{name: "adult_beijing", data: map[string]interface{}{"age": 20, "level": 1, "city": "beijing"}, all: []string{"adult", "beijing"}, first: "adult", best: "beijing"},
+		{name: "child_shanghai", data: map[string]interface{}{"age": 10, "level": 5, "city": "shanghai"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			all, err := rs.MatchAll(tt.data)
+			var setErr *RuleSetError
+			if !errors.As(err, &setErr) || len(setErr.Errors) != 1 || setErr.Errors[0].ID != "broken" {
+				t.Errorf("goParser ruleset want broken rule error, err=%v", err)
+			}
+			if !reflect.DeepEqual(ids(all), tt.all) {
+				t.Errorf("goParser ruleset match all=%v, want %v", ids(all), tt.all)
+			}
+			if calls != 1 {
+				t.Errorf("goParser ruleset shared sub-expression evaluated %d times, want 1", calls)
+			}
+
+			first, _ := rs.MatchFirst(tt.data)
+			best, _ := rs.MatchBest(tt.data)
+			if id(first) != tt.first || id(best) != tt.best {
+				t.Errorf("goParser ruleset first=%s best=%s, want %s %s", id(first), id(best), tt.first, tt.best)
+			}
+		})
+	}
+
+	if all, err := rs.MatchAll(nil); all != nil || err != nil {
+		t.Errorf("goParser ruleset nil data want no match, got=%v, err=%v", all, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := rs.MatchAllContext(ctx, map[string]interface{}{"age": 20}); !errors.Is(err, context.Canceled) {
+		t.Errorf("goParser ruleset canceled context want context.Canceled, err=%v", err)
+	}
+
+	// 比较、逻辑运算等由虚拟机直接计算的公共子表达式同样只计算一次
+	shared := NewRuleSet()
+	for i, expr := range []string{
+		`age >= 18 && country == "US"`,
+		`age >= 18 && country == "US" && level > 3`,
+		`!(age >= 18 && country == "US")`,
+	} {
+		if err := shared.Add(fmt.Sprintf("shared%d", i), 0, expr); err != nil {
+			t.Fatalf("goParser ruleset add failed, err=%v", err)
+		}
+	}
+	metrics := NewMetrics()
+	all, err := shared.MatchAllContext(WithObserver(context.Background(), metrics), map[string]interface{}{"age": 20, "country": "US", "level": 5})
+	if !reflect.DeepEqual(ids(all), []string{"shared0", "shared1"}) || err != nil {
+		t.Errorf("goParser ruleset shared match all=%v, err=%v", ids(all), err)
+	}
+	if got := metrics.Snapshot().Vars["age"]; got != 1 {
+		t.Errorf("goParser ruleset shared comparison evaluated %d times, want 1", got)
+	}
+}
+
+func TestGoParser_Filter(t *testing.T) {
+	p, err := Compile(`age >= 18 && 100 / score > 1`)
+	if err != nil {
+		t.Fatalf("goParser compile failed, err=%v", err)
+	}
+
+	var records []map[string]interface{}
+	var wantMatches, wantErrors []int
+	for i := 0; i < 1000; i++ {
+		record := map[string]interface{}{"age": i % 40, "score": i % 150}
+		// && 左侧不成立时右侧的除法不会被计算
+		switch {
+		case i%40 < 18:
+		case i%150 == 0:
+			wantErrors = append(wantErrors, i)
+		case 100/(i%150) > 1:
+			wantMatches = append(wantMatches, i)
+		}
+		records = append(records, record)
+	}
+	records = append(records, nil)
+
+	check := func(name string, result *FilterResult, err error) {
+		if err != nil {
+			t.Fatalf("goParser filter %s failed, err=%v", name, err)
+		}
+		if !reflect.DeepEqual(result.Matches, wantMatches) {
+			t.Errorf("goParser filter %s matches=%v, want %v", name, result.Matches, wantMatches)
+		}
+		var gotErrors []int
+		for _, e := range result.Errors {
+			if !errors.Is(e, ErrDivisionByZero) {
+				t.Errorf("goParser filter %s want division by zero, err=%v", name, e)
+			}
+			gotErrors = append(gotErrors, e.Index)
+		}
+		if !reflect.DeepEqual(gotErrors, wantErrors) {
+			t.Errorf("goParser filter %s errors=%v, want %v", name, gotErrors, wantErrors)
+		}
+	}
+
+	for _, workers := range []int{0, 1, 8} {
+		result, err := FilterSlice(p, records, Workers(workers))
+		check(fmt.Sprintf("slice_workers_%d", workers), result, err)
+	}
+
+	i := 0
+	result, err := FilterIter(p, IterFunc(func() (map[string]interface{}, bool) {
+		if i >= len(records) {
+			return nil, false
+		}
+		i++
+		return records[i-1], true
+	}), Workers(4))
+	check("iter", result, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FilterSliceContext(ctx, p, records); !errors.Is(err, context.Canceled) {
+		t.Errorf("goParser filter canceled context want context.Canceled, err=%v", err)
+	}
+
+	// 每条记录单独通过解析器获取变量，不复用其他记录已解析的值
+	scores := []interface{}{10, 1}
+	var calls int
+	resolver := ResolverFunc(func(name string) (interface{}, bool, error) {
+		calls++
+		return scores[(calls-1)%len(scores)], true, nil
+	})
+	p, err = Compile(`score > 5`)
+	if err != nil {
+		t.Fatalf("goParser compile failed, err=%v", err)
+	}
+	twoRecords := []map[string]interface{}{{"id": 1}, {"id": 2}}
+	result, err = FilterSliceContext(WithResolver(context.Background(), resolver), p, twoRecords, Workers(1))
+	if err != nil || !reflect.DeepEqual(result.Matches, []int{0}) || calls != 2 {
+		t.Errorf("goParser filter resolver matches=%v, calls=%d, err=%v", result, calls, err)
+	}
+}
+
+func TestGoParser_RuleSetIndex(t *testing.T) {
+	exprs := []string{
+		`a == 1 && b > 2`,
+		`(a == 2) && city == "beijing"`,
+		`city == "shanghai"`,
+		`in_array(city, []string{"beijing", "shanghai"}) && b < 5`,
+		`in_array(a, []int{1, 3, 3})`,
+		`a == 0`,
+		`b > 2 || a == 1`,
+		`in_array(a, []int{1, -b})`,
+	}
+	rs := NewRuleSet()
+	for i, expr := range exprs {
+		if err := rs.Add(fmt.Sprint(i), i%3, expr); err != nil {
+			t.Fatalf("goParser ruleset add failed, err=%v", err)
+		}
+	}
+	if len(rs.index) != 2 || len(rs.unindexed) != 2 {
+		t.Errorf("goParser ruleset index fields=%d unindexed=%d, want 2 2", len(rs.index), len(rs.unindexed))
+	}
+
+	records := []map[string]interface{}{
+		{"a": 1, "b": 3, "city": "beijing"},
+		{"a": int64(2), "b": 1, "city": "beijing"},
+		{"a": "3", "b": 4, "city": "shanghai"},
+		{"b": 10},
+		{"a": "x", "b": 1, "city": "guangzhou"},
+		{"a": 1.0, "b": json.Number("3"), "city": "shanghai"},
+	}
+	for i, data := range records {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			var want, wantErrs []string
+			for _, rule := range rs.Rules() {
+				ok, err := rule.Program.Match(data)
+				switch {
+				case err != nil:
+					wantErrs = append(wantErrs, rule.ID)
+				case ok:
+					want = append(want, rule.ID)
+				}
+			}
+
+			all, err := rs.MatchAll(data)
+			var got, gotErrs []string
+			for _, rule := range all {
+				got = append(got, rule.ID)
+			}
+			var setErr *RuleSetError
+			if errors.As(err, &setErr) {
+				for _, e := range setErr.Errors {
+					gotErrs = append(gotErrs, e.ID)
+				}
+			}
+			if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(gotErrs, wantErrs) {
+				t.Errorf("goParser ruleset index match=%v errors=%v, want %v %v", got, gotErrs, want, wantErrs)
+			}
+		})
+	}
+}
+
+func BenchmarkGoParser_RuleSetIndex(b *testing.B) {
+	rs := NewRuleSet()
+	for i := 0; i < 5000; i++ {
+		expr := fmt.Sprintf(`channel == "c%d" && in_array(level, []int{%d, %d}) && score >= %d`, i%500, i%7, i%11, i%100)
+		if err := rs.Add(fmt.Sprint(i), i%10, expr); err != nil {
+			b.Fatal(err)
+		}
+	}
+	data := map[string]interface{}{"channel": "c42", "level": 3, "score": 60}
+
+	b.Run("index", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = rs.MatchAll(data)
+		}
+	})
+	b.Run("naive", func(b *testing.B) {
+		rules := rs.Rules()
+		for i := 0; i < b.N; i++ {
+			for _, rule := range rules {
+				_, _ = rule.Program.Match(data)
+			}
+		}
+	})
+}
+
+func TestGoParser_Optimize(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{name: "nested_and", expr: `((a > 1 && b < 2) && c == 3)`, want: `a > 1 && b < 2 && c == 3`},
+		{name: "right_nested_or", expr: `a == 1 || (b == 2 || (c == 3))`, want: `a == 1 || b == 2 || c == 3`},
+		{name: "mixed_precedence", expr: `(a == 1 || b == 2) && (c == 3 && d == 4)`, want: `(a == 1 || b == 2) && c == 3 && d == 4`},
+		{name: "higher_precedence", expr: `a == 1 || (b == 2 && c == 3)`, want: `a == 1 || b == 2 && c == 3`},
+		{name: "fold_int", expr: `ttl < 60 * 60 * 24`, want: `ttl < 86400`},
+		{name: "fold_float", expr: `price * (1 + 0.5) > 10 / 4.0`, want: `price * 1.5 > 2.5`},
+		{name: "fold_float_integral", expr: `x > 1.5 + 1.5`, want: `x > 3.0`},
+		{name: "fold_mixed_float_left", expr: `x > 1.5 + 1`, want: `x > 2.5`},
+		{name: "fold_mixed_float_right", expr: `x < 1 + 1.5 * 2`, want: `x < 4.0`},
+		{name: "fold_mixed_compare", expr: `10.5 > 10 && x > 1`, want: `true && x > 1`},
+		{name: "float_rem_kept", expr: `x > 7.5 % 2`, want: `x > 7.5 % 2`},
+		{name: "fold_string", expr: `"a" == "a" && b == 1`, want: `true && b == 1`},
+		{name: "fold_unary", expr: `x > -(2 + 3)`, want: `x > -5`},
+		{name: "keep_arith_parens", expr: `(a - (b - c)) * (d + e)`, want: `(a - (b - c)) * (d + e)`},
+		{name: "left_assoc_parens", expr: `(a - b) - c`, want: `a - b - c`},
+		{name: "div_by_zero_kept", expr: `a > 1 / 0`, want: `a > 1 / 0`},
+		{name: "double_negation", expr: `!!(a > 1)`, want: `a > 1`},
+		{name: "double_negation_var", expr: `!!a`, want: `!!a`},
+		{name: "eq_true", expr: `(a > 1) == true`, want: `a > 1`},
+		{name: "eq_false", expr: `(a > 1 && b) == false`, want: `!(a > 1 && b)`},
+		{name: "neq_true_not", expr: `!(a == 1) != true`, want: `a == 1`},
+		{name: "true_eq", expr: `true == (a < 1)`, want: `a < 1`},
+		{name: "var_eq_true", expr: `a == true`, want: `a == true`},
+		{name: "func_args", expr: `in_array(a, []int{1 + 1, (3)}) && if((b), 1 * 2, 3) > 1`, want: `in_array(a, []int{2, 3}) && if(b, 2, 3) > 1`},
+		{name: "double_unary", expr: `-(-a) > 1`, want: `-(-a) > 1`},
+	}
+	data := map[string]interface{}{"a": 2, "b": true, "c": 3, "d": 4, "e": 1, "x": 4, "price": 3, "ttl": 100}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Optimize(tt.expr)
+			if err != nil || got != tt.want {
+				t.Fatalf("goParser optimize got=%s, err=%v, want %s", got, err, tt.want)
+			}
+			// 优化后的表达式计算结果保持不变
+			v1, err1 := EvalValue(tt.expr, data)
+			v2, err2 := EvalValue(got, data)
+			if !reflect.DeepEqual(v1, v2) || (err1 == nil) != (err2 == nil) {
+				t.Errorf("goParser optimize changed result: %v(%v) => %v(%v)", v1, err1, v2, err2)
+			}
+			p, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("goParser compile failed, err=%v", err)
+			}
+			if v3, err3 := p.Eval(data); !reflect.DeepEqual(v1, v3) || (err1 == nil) != (err3 == nil) {
+				t.Errorf("goParser compiled program changed result: %v(%v) => %v(%v)", v1, err1, v3, err3)
+			}
+		})
+	}
+
+	if _, err := Optimize("a ==="); err == nil {
+		t.Errorf("goParser optimize invalid expression want error")
+	}
+	if _, err := Optimize("a[0] == 1"); err == nil {
+		t.Errorf("goParser optimize unsupported syntax want error")
+	}
+}
+
+func TestGoParser_VM(t *testing.T) {
+	RegisterStdlib()
+	values := []string{"1", "-3", "0", "2.5", "0.0", `"a"`, `"1"`, "true", "false", "i", "i64", "f", "f32", "s", "sn", "b", "n", "j", "l"}
+	ops := []string{"==", "!=", ">", "<", ">=", "<=", "+", "-", "*", "/", "%", "&", "|", "^", "&^", "<<", ">>", "&&", "||"}
+	data := map[string]interface{}{
+		"i": 7, "i64": int64(math.MaxInt64), "f": 1.5, "f32": float32(2), "s": "a", "sn": "12",
+		"b": true, "n": nil, "j": json.Number("3"), "l": []interface{}{1, 2},
+	}
+
+	var exprs []string
+	for _, x := range values {
+		for _, y := range values {
+			for _, op := range ops {
+				exprs = append(exprs, x+" "+op+" "+y)
+			}
+		}
+		exprs = append(exprs, "-"+x, "!"+x, "^"+x, "+"+x)
+	}
+	exprs = append(exprs,
+		`(i > 1 && s == "a") || !(f < 1.0) && in_array(i, []int{1, 7})`,
+		`max(i, i64 - 1) * 2`,
+		`strings.HasPrefix(s, "a") && s.Len() == 1`,
+		`if(b, i * 2, f) + 1`,
+		`-(-i64 - 1)`,
+		`i64 + 1 > 0`,
+		`missing == 1`,
+	)
+
+	for _, expr := range exprs {
+		p, err := Compile(expr)
+		if err != nil {
+			continue
+		}
+		want := Eval(p.root, data)
+		got, err := p.Eval(data)
+		if wantErr, ok := want.(error); ok {
+			if err == nil || err.Error() != wantErr.Error() {
+				t.Errorf("goParser vm %s err=%v, want %v", expr, err, wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("goParser vm %s got=%#v(%v), want %#v", expr, got, err, want)
+		}
+	}
+
+	// 常用规则计算过程中没有内存分配
+	p, err := Compile(`(i == 7 && s == "a" && f >= 1.0) || (b == false) || i64 - i > 0`)
+	if err != nil {
+		t.Fatalf("goParser compile failed, err=%v", err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if ok, err := p.Match(data); !ok || err != nil {
+			t.Fatalf("goParser vm match failed, got=%v, err=%v", ok, err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("goParser vm match allocs=%v, want 0", allocs)
+	}
+
+	// 步数限制与上下文
+	if _, err := p.MatchContext(WithMaxSteps(context.Background(), 3), data); !errors.Is(err, ErrStepLimitExceeded) {
+		t.Errorf("goParser vm want step limit exceeded, err=%v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.MatchContext(ctx, data); !errors.Is(err, context.Canceled) {
+		t.Errorf("goParser vm canceled context want context.Canceled, err=%v", err)
+	}
+}
+
+func TestGoParser_Explain(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		data map[string]interface{}
+		want string
+	}{
+		{
+			name: "and",
+			expr: `age >= 18 && vip`,
+			data: map[string]interface{}{"age": 16, "vip": true},
+			want: "age >= 18 && vip → false\n" +
+				"  age >= 18 → false (age = 16)\n" +
+				"  vip [short-circuited]",
+		},
+		{
+			name: "nested",
+			expr: `(age >= 18 && (score + bonus) * 2 > 100) || !(name == "x")`,
+			data: map[string]interface{}{"age": 16, "score": 30, "bonus": 5, "name": "x"},
+			want: "(age >= 18 && (score + bonus) * 2 > 100) || !(name == \"x\") → false\n" +
+				"  age >= 18 && (score + bonus) * 2 > 100 → false\n" +
+				"    age >= 18 → false (age = 16)\n" +
+				"    (score + bonus) * 2 > 100 [short-circuited]\n" +
+				"  !(name == \"x\") → false\n" +
+				"    name == \"x\" → true (name = \"x\")",
+		},
+		{
+			name: "call",
+			expr: `in_array(city, []string{"bj"}) || a > 1 / 0`,
+			data: map[string]interface{}{"city": "sh", "a": 1},
+			want: "in_array(city, []string{\"bj\"}) || a > 1 / 0 → error: false || 1 > 1 / 0: integer divide by zero eval failed eval failed\n" +
+				"  in_array(city, []string{\"bj\"}) → false (city = \"sh\")\n" +
+				"  a > 1 / 0 → error: 1 > 1 / 0: integer divide by zero eval failed (a = 1)\n" +
+				"    1 / 0 → error: 1 / 0: integer divide by zero",
+		},
+		{
+			name: "call_args",
+			expr: `max(a, b * 2) > 5 && name.HasPrefix(prefix) && in_array(a, []int{1, b})`,
+			data: map[string]interface{}{"a": 3, "b": 4, "name": "alice", "prefix": "al"},
+			want: "max(a, b * 2) > 5 && name.HasPrefix(prefix) && in_array(a, []int{1, b}) → false\n" +
+				"  max(a, b * 2) > 5 && name.HasPrefix(prefix) → true\n" +
+				"    max(a, b * 2) > 5 → true\n" +
+				"      max(a, b * 2) → 8 (a = 3)\n" +
+				"        b * 2 → 8 (b = 4)\n" +
+				"    name.HasPrefix(prefix) → true (name = \"alice\", prefix = \"al\")\n" +
+				"  in_array(a, []int{1, b}) → false (a = 3)\n" +
+				"    []int{1, b} → [1 4] (b = 4)",
+		},
+		{
+			name: "ident",
+			expr: `vip`,
+			data: map[string]interface{}{"vip": true},
+			want: "vip → true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node, err := Explain(tt.expr, tt.data)
+			if err != nil {
+				t.Fatalf("goParser explain failed, err=%v", err)
+			}
+			if got := node.String(); got != tt.want {
+				t.Errorf("goParser explain got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			// 根节点结果与 EvalValue 一致
+			want, wantErr := EvalValue(tt.expr, tt.data)
+			if !reflect.DeepEqual(node.Value, want) || (node.Err == nil) != (wantErr == nil) {
+				t.Errorf("goParser explain root=%v(%v), want %v(%v)", node.Value, node.Err, want, wantErr)
+			}
+		})
+	}
+
+	// 函数调用的子节点与语法树一致，每个参数一个子节点
+	node, err := Explain(`max(a, b * 2, 1) > 5`, map[string]interface{}{"a": 3, "b": 4})
+	if err != nil {
+		t.Fatalf("goParser explain failed, err=%v", err)
+	}
+	var args []string
+	for _, child := range node.Children[0].Children {
+		args = append(args, fmt.Sprintf("%s=%v", child.Expr, child.Value))
+	}
+	if want := []string{"a=3", "b * 2=8", "1=1"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("goParser explain call args got=%v, want %v", args, want)
+	}
+
+	if _, err := Explain("a ===", nil); err == nil {
+		t.Errorf("goParser explain invalid expression want error")
+	}
+
+	// 计算出错的节点序列化 error 字段
+	node, err = Explain("a / b > 1", map[string]interface{}{"a": 1, "b": 0})
+	if err != nil {
+		t.Fatalf("goParser explain failed, err=%v", err)
+	}
+	b, err := json.Marshal(node)
+	if err != nil {
+		t.Fatalf("goParser explain marshal failed, err=%v", err)
+	}
+	var decoded struct {
+		Expr     string `json:"expr"`
+		Error    string `json:"error"`
+		Children []struct {
+			Expr  string      `json:"expr"`
+			Value interface{} `json:"value"`
+			Error string      `json:"error"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil || decoded.Expr != "a / b > 1" || !strings.Contains(decoded.Error, "divide by zero") ||
+		len(decoded.Children) != 2 || !strings.Contains(decoded.Children[0].Error, "divide by zero") || decoded.Children[1].Error != "" {
+		t.Errorf("goParser explain marshal got=%s, err=%v", b, err)
+	}
+}
+
+func TestGoParser_ShortCircuit(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		{name: "and_guard", expr: `x != 0 && 10 / x > 1`, want: false},
+		{name: "or_guard", expr: `x == 0 || 10 / x > 1`, want: true},
+		{name: "nested_guard", expr: `(x != 0 && 10 / x > 1) || missing.Len() == 0`, want: true},
+		{name: "chain_guard", expr: `x != 0 && y > 1 && 10 / x > 1`, want: false},
+	}
+	data := map[string]interface{}{"x": 0, "y": 5, "missing": ""}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := EvalValue(tt.expr, data); got != tt.want || err != nil {
+				t.Errorf("goParser short-circuit EvalValue got=%v, err=%v", got, err)
+			}
+			if got, err := Match(tt.expr, data); got != tt.want || err != nil {
+				t.Errorf("goParser short-circuit Match got=%v, err=%v", got, err)
+			}
+			p, err := Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("goParser compile failed, err=%v", err)
+			}
+			if got, err := p.Eval(data); got != tt.want || err != nil {
+				t.Errorf("goParser short-circuit Program.Eval got=%v, err=%v", got, err)
+			}
+			if got, err := p.MatchContext(context.Background(), data); got != tt.want || err != nil {
+				t.Errorf("goParser short-circuit Program.MatchContext got=%v, err=%v", got, err)
+			}
+			if res, err := PartialEval(tt.expr, data); err != nil || !res.Decided || res.Value != tt.want {
+				t.Errorf("goParser short-circuit PartialEval got=%+v, err=%v", res, err)
+			}
+		})
+	}
+
+	// 被短路的右侧节点没有计算结果
+	node, err := Explain(`x != 0 && 10 / x > 1`, data)
+	if err != nil || node.Value != false || node.Err != nil {
+		t.Fatalf("goParser explain short-circuit got=%v, err=%v", node, err)
+	}
+	if y := node.Children[1]; !y.ShortCircuited || y.Value != nil || y.Err != nil || len(y.Children) != 0 {
+		t.Errorf("goParser explain short-circuit right node=%+v", y)
+	}
+	if clauses, err := WhyNot(`x != 0 && 10 / x > 1`, data); err != nil || len(clauses) != 2 || clauses[1].Err == nil {
+		t.Errorf("goParser whynot short-circuit got=%+v, err=%v", clauses, err)
+	}
+}
+
+func TestGoParser_WhyNot(t *testing.T) {
+	data := map[string]interface{}{"age": 16, "name": "tom", "city": "sh", "level": 2}
+	tests := []struct {
+		name string
+		expr string
+		want []FailedClause
+	}{
+		{name: "matched", expr: `age > 10 && name == "tom"`},
+		{
+			name: "and",
+			expr: `age >= 18 && name == "tom" && (level > 3 && city == "sh")`,
+			want: []FailedClause{
+				{ChildrenItem: ChildrenItem{Field: "age", Op: "GE", Value: int64(18)}, Expr: "age >= 18", Actual: 16},
+				{ChildrenItem: ChildrenItem{Field: "level", Op: "GT", Value: int64(3)}, Expr: "level > 3", Actual: 2},
+			},
+		},
+		{
+			name: "or",
+			expr: `age >= 18 || in_array(city, []string{"bj"}) || level + 1 > 5`,
+			want: []FailedClause{
+				{ChildrenItem: ChildrenItem{Field: "age", Op: "GE", Value: int64(18)}, Expr: "age >= 18", Actual: 16},
+				{Expr: `in_array(city, []string{"bj"})`, Actual: false},
+				{ChildrenItem: ChildrenItem{Field: "level + 1", Op: "GT", Value: int64(5)}, Expr: "level + 1 > 5", Actual: int64(3)},
+			},
+		},
+		{
+			name: "not",
+			expr: `!(name == "tom" && age < 18)`,
+			want: []FailedClause{
+				{ChildrenItem: ChildrenItem{Field: "name", Op: "EQ", Value: "tom"}, Expr: `name == "tom"`, Actual: "tom", Negated: true},
+				{ChildrenItem: ChildrenItem{Field: "age", Op: "LT", Value: int64(18)}, Expr: "age < 18", Actual: 16, Negated: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WhyNot(tt.expr, data)
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("goParser whynot got=%+v, err=%v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+
+	var tree map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+		"connector": "AND",
+		"children": [
+			{"op": "GE", "field": "age", "value": 18},
+			{"connector": "OR", "children": [
+				{"op": "EQ", "field": "city", "value": "bj"},
+				{"op": "EQ", "field": "name", "value": "tom"}
+			]},
+			{"op": "EQ", "field": "city", "value": "gz"}
+		]
+	}`), &tree); err != nil {
+		t.Fatal(err)
+	}
+	got, err := WhyNotTree(tree, data)
+	want := []FailedClause{
+		{ChildrenItem: ChildrenItem{Field: "age", Op: "GE", Value: float64(18)}, Expr: "age >= 18", Actual: 16},
+		{ChildrenItem: ChildrenItem{Field: "city", Op: "EQ", Value: "gz"}, Expr: `city == "gz"`, Actual: "sh"},
+	}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("goParser whynot tree got=%+v, err=%v, want %+v", got, err, want)
+	}
+
+	if _, err := WhyNot(`a + 1`, map[string]interface{}{"a": 1}); err == nil {
+		t.Errorf("goParser whynot non-bool expression want error")
+	}
+}
+
+func TestGoParser_PartialEval(t *testing.T) {
+	known := map[string]interface{}{"country": "cn", "device": "ios", "version": 12, "ratio": 0.5, "tags": []string{"a"},
+		"small": int32(3), "count": uint(7), "weight": float32(1.5), "rank": uint8(2), "total": json.Number("8")}
+	tests := []struct {
+		name     string
+		expr     string
+		decided  bool
+		value    bool
+		residual string
+		vars     []string
+	}{
+		{name: "decided_false", expr: `country == "us" && age > 18`, decided: true, value: false},
+		{name: "decided_true", expr: `device == "ios" || age > 18`, decided: true, value: true},
+		{name: "all_known", expr: `country == "cn" && version >= 10`, decided: true, value: true},
+		{name: "residual_and", expr: `country == "cn" && (age > 18 && device != "android")`, residual: `age > 18`, vars: []string{"age"}},
+		{name: "residual_or", expr: `device == "android" || vip || level > 3`, residual: `vip || level > 3`, vars: []string{"vip", "level"}},
+		{name: "substitute", expr: `score * ratio > version + 1 && country == "cn"`, residual: `score * 0.5 > 13`, vars: []string{"score"}},
+		{name: "call_args", expr: `in_array(city, []string{country, "us"}) && max(level, version) > 20`, residual: `in_array(city, []string{"cn", "us"}) && max(level, 12) > 20`, vars: []string{"city", "level"}},
+		{name: "known_call", expr: `tags.Len() == 1 && !(name == "x")`, residual: `!(name == "x")`, vars: []string{"name"}},
+		{name: "not_folded", expr: `!(country == "cn" && vip)`, residual: `!vip`, vars: []string{"vip"}},
+		{name: "number_types", expr: `score > max(small, count) + 1 && max(level, rank, total) * weight > 1`, residual: `score > 8 && max(level, 2, 8) * 1.5 > 1`, vars: []string{"score", "level"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PartialEval(tt.expr, known)
+			if err != nil {
+				t.Fatalf("goParser partial eval failed, err=%v", err)
+			}
+			want := &PartialResult{Decided: tt.decided, Value: tt.value, Residual: tt.residual, Vars: tt.vars}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("goParser partial eval got=%+v, want %+v", got, want)
+			}
+		})
+	}
+
+	// 剩余表达式与完整数据计算的结果一致
+	full := map[string]interface{}{"age": 20, "vip": false, "level": 5, "score": 40, "city": "cn", "name": "y"}
+	for k, v := range known {
+		full[k] = v
+	}
+	for _, tt := range tests {
+		got, _ := PartialEval(tt.expr, known)
+		want, err := Match(tt.expr, full)
+		if err != nil {
+			t.Fatalf("goParser match %s failed, err=%v", tt.expr, err)
+		}
+		if got.Decided && got.Value != want {
+			t.Errorf("goParser partial eval %s decided %v, want %v", tt.expr, got.Value, want)
+		}
+		if !got.Decided {
+			if residual, err := Match(got.Residual, full); err != nil || residual != want {
+				t.Errorf("goParser residual %s got=%v, err=%v, want %v", got.Residual, residual, err, want)
+			}
+		}
+	}
+
+	if _, err := PartialEval(`tags == x`, known); err == nil {
+		t.Errorf("goParser partial eval unrepresentable value want error")
+	}
+	if _, err := PartialEval(`country + 1 > 0`, known); err == nil {
+		t.Errorf("goParser partial eval known error want error")
+	}
+	if _, err := PartialEval(`max(x, big) > 1`, map[string]interface{}{"big": uint64(math.MaxUint64)}); err == nil || !errors.Is(err, ErrIntegerOverflow) {
+		t.Errorf("goParser partial eval uint64 overflow err=%v", err)
+	}
+}
+
+func TestGoParser_Resolver(t *testing.T) {
+	store := map[string]interface{}{"age": 20, "country": "cn", "level": 3}
+	calls := map[string]int{}
+	var mu sync.Mutex
+	resolver := ResolverFunc(func(name string) (interface{}, bool, error) {
+		mu.Lock()
+		calls[name]++
+		mu.Unlock()
+		if name == "broken" {
+			return nil, false, errors.New("store unavailable")
+		}
+		v, ok := store[name]
+		return v, ok, nil
+	})
+	reset := func() { calls = map[string]int{} }
+
+	// 只获取表达式实际引用的变量，同一变量只获取一次
+	ok, err := MatchResolver(context.Background(), `age > 18 && age < 60 && max(age, level) == 20`, resolver)
+	if err != nil || !ok {
+		t.Fatalf("goParser match resolver got=%v, err=%v", ok, err)
+	}
+	if !reflect.DeepEqual(calls, map[string]int{"age": 1, "level": 1}) {
+		t.Errorf("goParser match resolver calls=%v", calls)
+	}
+
+	reset()
+	p, err := Compile(`country == "cn" && age >= 18 || country == "us"`)
+	if err != nil {
+		t.Fatalf("goParser compile failed, err=%v", err)
+	}
+	if ok, err := p.MatchResolver(context.Background(), resolver); err != nil || !ok {
+		t.Errorf("goParser program match resolver got=%v, err=%v", ok, err)
+	}
+	if !reflect.DeepEqual(calls, map[string]int{"country": 1, "age": 1}) {
+		t.Errorf("goParser program match resolver calls=%v", calls)
+	}
+
+	// 数据中已有的变量优先，不存在的变量为nil
+	reset()
+	ctx := WithResolver(context.Background(), resolver)
+	if ok, err := MatchContext(ctx, `age == 30`, map[string]interface{}{"age": 30}); err != nil || !ok {
+		t.Errorf("goParser match data first got=%v, err=%v", ok, err)
+	}
+	if _, err := p.MatchResolver(context.Background(), ResolverFunc(func(string) (interface{}, bool, error) { return nil, false, nil })); err == nil {
+		t.Errorf("goParser match unresolved var want error")
+	}
+	if _, err := MatchResolver(context.Background(), `broken > 1`, resolver); err == nil || !strings.Contains(err.Error(), "resolve broken: store unavailable") {
+		t.Errorf("goParser match resolver error got=%v", err)
+	}
+	if len(calls) != 1 || calls["broken"] != 1 {
+		t.Errorf("goParser match resolver calls=%v", calls)
+	}
+
+	// 规则集的索引只使用数据中已有的字段，其余字段在规则计算时通过解析器获取，多条规则间共享已解析的变量
+	reset()
+	rs := NewRuleSet()
+	for id, expr := range map[string]string{"cn": `country == "cn" && age > 18`, "us": `country == "us"`, "level": `level > 1 && age > 30`} {
+		if err := rs.Add(id, 0, expr); err != nil {
+			t.Fatalf("goParser ruleset add failed, err=%v", err)
+		}
+	}
+	rules, err := rs.MatchAllContext(WithResolver(context.Background(), resolver), map[string]interface{}{})
+	if err != nil || len(rules) != 1 || rules[0].ID != "cn" {
+		t.Errorf("goParser ruleset match resolver got=%v, err=%v", rules, err)
+	}
+	if !reflect.DeepEqual(calls, map[string]int{"country": 1, "age": 1, "level": 1}) {
+		t.Errorf("goParser ruleset match resolver calls=%v", calls)
+	}
+
+	// 先匹配的规则不引用索引字段时，索引字段不会被解析
+	reset()
+	rs = NewRuleSet()
+	for _, r := range []struct{ id, expr string }{{"age", `age > 18`}, {"cn", `country == "cn"`}} {
+		if err := rs.Add(r.id, 0, r.expr); err != nil {
+			t.Fatalf("goParser ruleset add failed, err=%v", err)
+		}
+	}
+	rule, err := rs.MatchFirstContext(WithResolver(context.Background(), resolver), map[string]interface{}{})
+	if err != nil || rule == nil || rule.ID != "age" {
+		t.Errorf("goParser ruleset match first resolver got=%v, err=%v", rule, err)
+	}
+	if !reflect.DeepEqual(calls, map[string]int{"age": 1}) {
+		t.Errorf("goParser ruleset match first resolver calls=%v", calls)
+	}
+
+	// 数据中已有的索引字段直接用于筛选规则
+	reset()
+	rule, err = rs.MatchFirstContext(WithResolver(context.Background(), resolver), map[string]interface{}{"country": "us", "age": 10})
+	if err != nil || rule != nil || len(calls) != 0 {
+		t.Errorf("goParser ruleset match first data got=%v, err=%v, calls=%v", rule, err, calls)
+	}
+
+	node, err := ExplainContext(WithResolver(context.Background(), resolver), `max(age, 1) > 18`, map[string]interface{}{})
+	if err != nil || node.String() != "max(age, 1) > 18 → true\n  max(age, 1) → 20 (age = 20)" {
+		t.Errorf("goParser explain resolver got=%v, err=%v", node, err)
+	}
+}
+
+// traceObserver 记录规则及函数调用顺序的观察者
+type traceObserver struct {
+	BaseObserver
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *traceObserver) OnRuleStart(id, expr string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, "start "+id)
+}
+
+func (o *traceObserver) OnRuleEnd(e RuleEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf("end %s matched=%v err=%v", e.ID, e.Matched, e.Err != nil))
+}
+
+func (o *traceObserver) OnFuncCall(e FuncEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf("func %s(%s) = %v", e.Name, strings.Join(e.Args, ", "), e.Result))
+}
+
+func TestGoParser_Observer(t *testing.T) {
+	trace := &traceObserver{}
+	ctx := WithObserver(context.Background(), trace)
+	if ok, err := MatchContext(ctx, `in_array(city, []string{"bj", "sh"}) && max(age, 1) > 18`, map[string]interface{}{"city": "bj", "age": 20}); err != nil || !ok {
+		t.Fatalf("goParser match observer got=%v, err=%v", ok, err)
+	}
+	want := []string{
+		"start " + `in_array(city, []string{"bj", "sh"}) && max(age, 1) > 18`,
+		`func in_array(city, []string{"bj", "sh"}) = true`,
+		"func max(age, 1) = 20",
+		"end " + `in_array(city, []string{"bj", "sh"}) && max(age, 1) > 18` + " matched=true err=false",
+	}
+	if !reflect.DeepEqual(trace.events, want) {
+		t.Errorf("goParser observer events=%q, want %q", trace.events, want)
+	}
+
+	metrics := NewMetrics(time.Millisecond, time.Second)
+	ctx = WithObserver(context.Background(), metrics)
+	rs := NewRuleSet()
+	for _, r := range []struct{ id, expr string }{
+		{"adult", `age >= 18`},
+		{"vip", `age >= 18 && level > 3`},
+		{"broken", `missing > 1`},
+	} {
+		if err := rs.Add(r.id, 0, r.expr); err != nil {
+			t.Fatalf("goParser ruleset add failed, err=%v", err)
+		}
+	}
+	for _, data := range []map[string]interface{}{{"age": 20, "level": 5}, {"age": 16, "level": 5}} {
+		_, _ = rs.MatchAllContext(ctx, data)
+	}
+	p, _ := Compile(`max(age, level) > 10`)
+	if _, err := FilterSlice(p, []map[string]interface{}{{"age": 20, "level": 1}, {"age": 2, "level": 1}, {"age": 30, "level": 1}}); err != nil {
+		t.Fatalf("goParser filter failed, err=%v", err)
+	}
+	if _, err := FilterSliceContext(ctx, p, []map[string]interface{}{{"age": 20, "level": 1}, {"age": 2, "level": 1}, {"age": 30, "level": 1}}, Workers(2)); err != nil {
+		t.Fatalf("goParser filter failed, err=%v", err)
+	}
+
+	s := metrics.Snapshot()
+	for id, want := range map[string][3]int64{"adult": {2, 1, 0}, "vip": {2, 1, 0}, "broken": {2, 0, 2}, `max(age, level) > 10`: {3, 2, 0}} {
+		got := s.Rules[id]
+		if [3]int64{got.Evals, got.Matched, got.Errors} != want || got.Latency.Count != want[0] || len(got.Latency.Counts) != 3 {
+			t.Errorf("goParser metrics rule %s got=%+v, want %v", id, got, want)
+		}
+	}
+	if got := s.Funcs["max"]; got.Calls != 3 || got.Errors != 0 {
+		t.Errorf("goParser metrics func got=%+v", got)
+	}
+	if !reflect.DeepEqual(s.Vars, map[string]int64{"age": 5, "level": 4, "missing": 2}) {
+		t.Errorf("goParser metrics vars got=%v", s.Vars)
+	}
+	if _, err := json.Marshal(s); err != nil {
+		t.Errorf("goParser metrics marshal failed, err=%v", err)
+	}
+
+	metrics.Reset()
+	if s := metrics.Snapshot(); len(s.Rules) != 0 || len(s.Funcs) != 0 || len(s.Vars) != 0 {
+		t.Errorf("goParser metrics reset got=%+v", s)
+	}
+}
+
+func TestGoParser_RuleStore(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("adult.json", `{"adult": {"priority": 1, "connector": "AND", "children": [{"op": "GE", "field": "age", "value": 18}]}}`)
+	write("vip.yaml", `
+vip:
+  priority: 10
+  connector: AND
+  children:
+    - {op: GE, field: age, value: 18}
+    - {op: GT, field: level, value: 3}
+`)
+	write("README.md", "not a rule file")
+
+	if _, err := NewRuleStore(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("goParser rule store missing path want error")
+	}
+	store, err := NewRuleStore(dir, StoreCompileOptions(LimitDepth(16)))
+	if err != nil {
+		t.Fatalf("goParser rule store load failed, err=%v", err)
+	}
+	v := store.Version()
+	if v.Version != 1 || v.Rules != 2 || len(v.Files) != 2 || v.Checksum == "" {
+		t.Errorf("goParser rule store version got=%+v", v)
+	}
+	best, err := store.RuleSet().MatchBest(map[string]interface{}{"age": 20, "level": 5})
+	if err != nil || best == nil || best.ID != "vip" {
+		t.Errorf("goParser rule store match got=%v, err=%v", best, err)
+	}
+
+	// 内容未变化时不重新加载
+	if changed, err := store.Reload(); changed || err != nil {
+		t.Errorf("goParser rule store unchanged reload got=%v, err=%v", changed, err)
+	}
+
+	// 编译失败时保留上一版本
+	old := store.RuleSet()
+	write("vip.yaml", `vip: {connector: AND, children: [{op: GE, field: "age >", value: 18}]}`)
+	if changed, err := store.Reload(); changed || err == nil {
+		t.Errorf("goParser rule store broken reload got=%v, err=%v", changed, err)
+	}
+	if store.RuleSet() != old || store.Version().Version != 1 || store.LastError() == nil {
+		t.Errorf("goParser rule store broken reload replaced version %+v", store.Version())
+	}
+	write("dup.json", `{"adult": {"connector": "AND", "children": [{"op": "EQ", "field": "x", "value": 1}]}}`)
+	if _, err := store.Reload(); err == nil || !strings.Contains(err.Error(), "duplicate rule adult") {
+		t.Errorf("goParser rule store duplicate got err=%v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "dup.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮询到文件变化后原子替换
+	reloaded := make(chan RuleVersion, 10)
+	var failures int32
+	store, err = NewRuleStore(filepath.Join(dir, "adult.json"), OnReload(func(v RuleVersion, err error) {
+		if err != nil {
+			atomic.AddInt32(&failures, 1)
+			return
+		}
+		reloaded <- v
+	}))
+	if err != nil {
+		t.Fatalf("goParser rule store load file failed, err=%v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- store.Watch(ctx, 5*time.Millisecond) }()
+
+	write("adult.json", `{"adult": {"connector": "AND", "children": [{"op": "GE", "field": "age", "value": 21}]}}`)
+	select {
+	case v := <-reloaded:
+		if v.Version != 2 || v.Rules != 1 {
+			t.Errorf("goParser rule store watch version got=%+v", v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("goParser rule store watch timeout")
+	}
+	if rules, _ := store.RuleSet().MatchAll(map[string]interface{}{"age": 20}); len(rules) != 0 {
+		t.Errorf("goParser rule store watch match got=%v", rules)
+	}
+
+	// 文件持续无法加载时只回调一次
+	write("adult.json", `{"adult": {"connector": "AND", "children": [{"op": "GE", "field": "age >", "value": 21}]}}`)
+	for deadline := time.Now().Add(2 * time.Second); atomic.LoadInt32(&failures) == 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&failures); n != 1 || store.Version().Version != 2 {
+		t.Errorf("goParser rule store watch broken file callbacks=%d, version=%+v", n, store.Version())
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("goParser rule store watch got err=%v", err)
+	}
+}
+
+func TestGoParser_ExpressionTree(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string // Expression 转换回的表达式
+	}{
+		{name: "leaf", expr: `age >= 18`, want: `age >= 18`},
+		{name: "chain", expr: `a > 1 && b == "x" && c < 1.5`, want: `((a > 1 && b == "x") && c < 1.5)`},
+		{name: "nested", expr: `a > 1 && (b == "x" || c != false)`, want: `(a > 1 && (b == "x" || c != false))`},
+		{name: "not_leaf", expr: `!(level > -3)`, want: `!(level > -3)`},
+		{name: "not_group", expr: `!(a == 1 || d == 2)`, want: `!(a == 1 || d == 2)`},
+		{name: "quoted", expr: `name == "a\"b" && path == "c:\\dir\n"`, want: `(name == "a\"b" && path == "c:\\dir\n")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := ExpressionTree(tt.expr)
+			if err != nil {
+				t.Fatalf("goParser expression tree failed, err=%v", err)
+			}
+			// 经过JSON序列化后依然可以转换
+			b, _ := json.Marshal(tree)
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Expression(decoded)
+			if err != nil || got != tt.want {
+				t.Errorf("goParser expression tree round trip got=%s, err=%v, want %s", got, err, tt.want)
+			}
+			// 转换回的表达式计算结果与原表达式一致
+			data := map[string]interface{}{"age": 20, "a": 2, "b": "x", "c": 1, "d": 2, "level": 0, "name": `a"b`, "path": "c:\\dir\n"}
+			v1, err1 := EvalValue(tt.expr, data)
+			v2, err2 := EvalValue(got, data)
+			if v1 != v2 || err1 != nil || err2 != nil {
+				t.Errorf("goParser expression tree round trip result %v(%v) => %v(%v)", v1, err1, v2, err2)
+			}
+		})
+	}
+
+	for _, expr := range []string{`a == b`, `a > b + 1`, `a`, `a[0] == 1`} {
+		if _, err := ExpressionTree(expr); err == nil {
+			t.Errorf("goParser expression tree %s want error", expr)
+		}
+	}
+
+	vars, err := ExportVars(`max(a, b) > c && name.HasPrefix(prefix) && a < 10`)
+	if err != nil || !reflect.DeepEqual(vars, []string{"a", "b", "c", "name", "prefix"}) {
+		t.Errorf("goParser export vars got=%v, err=%v", vars, err)
+	}
+}
+
+func TestGoParser_MatchCache(t *testing.T) {
+	defer SetMatchCacheSize(DefaultMatchCacheSize)
+	matchCache.Purge()
+	SetMatchCacheSize(2)
+
+	data := map[string]interface{}{"a": 1, "b": "x", "c": 2.5, "d": true}
+	for _, expr := range []string{`a == 1`, `a == 1`, `b == "x"`, `a == 1`, `c > 2.0`} {
+		if ok, err := Match(expr, data); err != nil || !ok {
+			t.Fatalf("goParser match cache %s got=%v, err=%v", expr, ok, err)
+		}
+	}
+	// c > 2.0 淘汰最久未使用的 b == "x"
+	if got, want := MatchCacheStats(), (CacheStats{Hits: 2, Misses: 3, Evictions: 1, Len: 2, Size: 2}); got != want {
+		t.Errorf("goParser match cache stats got=%+v, want %+v", got, want)
+	}
+
+	// 缓存的计算结果、错误与不使用缓存时一致
+	exprs := []string{
+		`(a == 1 && b == "x" && in_array(a, []int{1, 2})) || d == false`,
+		`a + 1`,
+		`missing > 1`,
+		`b > 1`,
+		`a[0] == 1`,
+		`c * 2 > 4 && !d`,
+		`if(d, a, 0) == 1`,
+	}
+	for _, expr := range exprs {
+		SetMatchCacheSize(DefaultMatchCacheSize)
+		ok, err := Match(expr, data)
+		cachedOk, cachedErr := Match(expr, data)
+		SetMatchCacheSize(0)
+		wantOk, wantErr := Match(expr, data)
+		if ok != wantOk || cachedOk != wantOk || (err == nil) != (wantErr == nil) || (cachedErr == nil) != (wantErr == nil) {
+			t.Errorf("goParser match cache %s got=%v/%v, err=%v/%v, want %v, err=%v", expr, ok, cachedOk, err, cachedErr, wantOk, wantErr)
+		}
+	}
+	if s := MatchCacheStats(); s.Len != 0 || s.Size != 0 {
+		t.Errorf("goParser match cache disabled stats got=%+v", s)
+	}
+
+	// 步数限制的计算结果与是否使用缓存无关：括号、被折叠的常量、函数调用均按原语法树节点计步
+	stepExprs := []string{
+		`(a == 1) && (b == 2)`,
+		`1 + 2 > a && (d || !d)`,
+		`!!d && c * 2 > 4.0 && d == true`,
+		`max(a, (2)) > 1 || b == "x"`,
+		`(false && a > 1) || in_array(a, []int{1, 2})`,
+	}
+	for _, expr := range stepExprs {
+		for max := int64(1); max <= 20; max++ {
+			SetMatchCacheSize(DefaultMatchCacheSize)
+			ok, err := MatchContext(WithMaxSteps(context.Background(), max), expr, data)
+			SetMatchCacheSize(0)
+			wantOk, wantErr := MatchContext(WithMaxSteps(context.Background(), max), expr, data)
+			if ok != wantOk || fmt.Sprint(err) != fmt.Sprint(wantErr) {
+				t.Errorf("goParser match cache %s max steps %d got=%v, err=%v, want %v, err=%v", expr, max, ok, err, wantOk, wantErr)
+			}
+		}
+	}
+	SetMatchCacheSize(DefaultMatchCacheSize)
+	if _, err := MatchContext(WithMaxSteps(context.Background(), 8), `(a == 1) && (b == 2)`, map[string]interface{}{"a": 1, "b": 2}); !errors.Is(err, ErrStepLimitExceeded) {
+		t.Errorf("goParser match cache max steps want ErrStepLimitExceeded, err=%v", err)
+	}
+
+	// 编译失败的错误同样被缓存，不会重复编译
+	SetMatchCacheSize(DefaultMatchCacheSize)
+	matchCache.Purge()
+	for i := 0; i < 3; i++ {
+		if _, err := Match(`a[0] == 1`, data); err == nil {
+			t.Errorf("goParser match cache unsupported syntax want error")
+		}
+	}
+	if got, want := MatchCacheStats(), (CacheStats{Hits: 2, Misses: 1, Len: 1, Size: DefaultMatchCacheSize}); got != want {
+		t.Errorf("goParser match cache failure stats got=%+v, want %+v", got, want)
+	}
+
+	c := NewProgramCache(10)
+	_, err1 := c.Compile(`a[0] == 1`)
+	_, err2 := c.Compile(`a[0] == 1`)
+	if err1 == nil || err1 != err2 {
+		t.Errorf("goParser program cache compile err=%v/%v", err1, err2)
+	}
+	if p, ok := c.Get(`a[0] == 1`); ok || p != nil {
+		t.Errorf("goParser program cache get failed expr got=%v", p)
+	}
+	p1, _ := c.Compile(`a > 1`, LimitDepth(8))
+	p2, _ := c.Compile(`a > 1`)
+	if p1 != p2 || c.Stats().Len != 2 || c.Stats().Hits != 3 {
+		t.Errorf("goParser program cache got=%p/%p, stats=%+v", p1, p2, c.Stats())
+	}
+
+	SetMatchCacheSize(4)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				expr := fmt.Sprintf("a + %d > %d", j%6, i)
+				want := 1+j%6 > i
+				if ok, err := Match(expr, data); err != nil || ok != want {
+					t.Errorf("goParser match cache concurrent %s got=%v, err=%v", expr, ok, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if s := MatchCacheStats(); s.Len > 4 || s.Hits+s.Misses < 400 {
+		t.Errorf("goParser match cache concurrent stats got=%+v", s)
+	}
+}
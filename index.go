@@ -0,0 +1,153 @@
+package goparser
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// fieldIndex 单个变量上的哈希索引，取值的比较方式与表达式计算一致
+type fieldIndex struct {
+	ints     map[int64][]*Rule  // 整数值 => 规则
+	strs     map[string][]*Rule // 字符串值 => 规则
+	intRules []*Rule            // 以整数值索引的全部规则，变量无法转换为整数时均作为候选规则
+	rules    []*Rule            // 索引中的全部规则，变量不存在时均作为候选规则
+}
+
+// add 将规则添加到索引
+func (idx *fieldIndex) add(rule *Rule, values []interface{}) {
+	idx.rules = append(idx.rules, rule)
+	seen := make(map[interface{}]bool, len(values))
+	hasInt := false
+	for _, value := range values {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		switch v := value.(type) {
+		case int64:
+			if !hasInt {
+				hasInt = true
+				idx.intRules = append(idx.intRules, rule)
+			}
+			if idx.ints == nil {
+				idx.ints = make(map[int64][]*Rule)
+			}
+			idx.ints[v] = append(idx.ints[v], rule)
+		case string:
+			if idx.strs == nil {
+				idx.strs = make(map[string][]*Rule)
+			}
+			idx.strs[v] = append(idx.strs[v], rule)
+		}
+	}
+}
+
+// lookup 查找变量取值可能匹配的规则并追加到 out
+func (idx *fieldIndex) lookup(value interface{}, out []*Rule) []*Rule {
+	// 变量不存在或获取出错时交由表达式计算返回错误
+	if _, isErr := value.(error); value == nil || isErr {
+		return append(out, idx.rules...)
+	}
+	if len(idx.strs) > 0 {
+		s, _ := castToString(value)
+		out = append(out, idx.strs[s]...)
+	}
+	if len(idx.ints) > 0 {
+		v, err := castToInt64(value)
+		if i, ok := v.(int64); err == nil && ok {
+			out = append(out, idx.ints[i]...)
+		} else {
+			// 无法转换时交由表达式计算返回错误
+			out = append(out, idx.intRules...)
+		}
+	}
+	return out
+}
+
+// indexPredicate 分析规则顶层 && 连接的条件，返回可建立索引的变量名及取值。
+// 支持 `变量 == 整数或字符串字面量` 及 `in_array(变量, []T{字面量...})`，优先选择等值条件
+func indexPredicate(root ast.Expr) (field string, values []interface{}, ok bool) {
+	var candidates []ast.Expr
+	conjuncts(root, &candidates)
+
+	for _, expr := range candidates {
+		if bin, isBin := expr.(*ast.BinaryExpr); isBin && bin.Op == token.EQL {
+			name, isVar := indexField(bin.X)
+			value, isLit := indexValue(bin.Y)
+			if isVar && isLit {
+				return name, []interface{}{value}, true
+			}
+		}
+	}
+	for _, expr := range candidates {
+		call, isCall := expr.(*ast.CallExpr)
+		if !isCall || len(call.Args) != 2 {
+			continue
+		}
+		if fun, isIdent := call.Fun.(*ast.Ident); !isIdent || fun.Name != "in_array" {
+			continue
+		}
+		name, isVar := indexField(call.Args[0])
+		list, isList := call.Args[1].(*ast.CompositeLit)
+		if !isVar || !isList {
+			continue
+		}
+		values = make([]interface{}, 0, len(list.Elts))
+		for _, elt := range list.Elts {
+			value, isLit := indexValue(elt)
+			if !isLit {
+				values = nil
+				break
+			}
+			values = append(values, value)
+		}
+		if values != nil {
+			return name, values, true
+		}
+	}
+	return "", nil, false
+}
+
+// conjuncts 展开顶层 && 连接的条件
+func conjuncts(expr ast.Expr, out *[]ast.Expr) {
+	expr = unparen(expr)
+	if bin, ok := expr.(*ast.BinaryExpr); ok && bin.Op == token.LAND {
+		conjuncts(bin.X, out)
+		conjuncts(bin.Y, out)
+		return
+	}
+	*out = append(*out, expr)
+}
+
+// unparen 去除括号
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.X
+	}
+}
+
+// indexField 可建立索引的变量名
+func indexField(expr ast.Expr) (string, bool) {
+	ident, ok := unparen(expr).(*ast.Ident)
+	if !ok || ident.Name == "true" || ident.Name == "false" {
+		return "", false
+	}
+	return ident.Name, true
+}
+
+// indexValue 可建立索引的整数或字符串字面量
+func indexValue(expr ast.Expr) (interface{}, bool) {
+	lit, ok := unparen(expr).(*ast.BasicLit)
+	if !ok || (lit.Kind != token.INT && lit.Kind != token.STRING) {
+		return nil, false
+	}
+	switch value := getlitValue(lit).(type) {
+	case int64, string:
+		return value, true
+	}
+	return nil, false
+}
@@ -0,0 +1,277 @@
+package goparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"reflect"
+	"strings"
+)
+
+// 方法接收者类型定义（标量类型与 castType 的类型名保持一致）
+const (
+	TypeList = "list" // 数组、切片
+	TypeMap  = "map"  // map
+)
+
+// Method 声明值方法类型，recv 为方法接收者的值，如 name.HasPrefix("a") 中的 name
+type Method func(recv interface{}, args []ast.Expr, data map[string]interface{}) interface{}
+
+// MethodContext 支持上下文的值方法类型
+type MethodContext func(ctx context.Context, recv interface{}, args []ast.Expr, data map[string]interface{}) interface{}
+
+// 注册值方法：接收者类型 => 方法名 => 方法
+var methodNameMap = make(map[string]map[string]MethodContext, 4)
+
+// 允许通过反射调用的结构体方法：结构体类型 => 方法名
+var allowedMethods = make(map[reflect.Type]map[string]bool)
+
+// init 值方法初始化
+func init() {
+	// 注册字符串方法
+	RegisterMethodContext(TypeString, "Len", stringLen)
+	RegisterMethodContext(TypeString, "Contains", stringMethod("Contains", strings.Contains))
+	RegisterMethodContext(TypeString, "HasPrefix", stringMethod("HasPrefix", strings.HasPrefix))
+	RegisterMethodContext(TypeString, "HasSuffix", stringMethod("HasSuffix", strings.HasSuffix))
+	RegisterMethodContext(TypeString, "EqualFold", stringMethod("EqualFold", strings.EqualFold))
+	RegisterMethodContext(TypeString, "ToLower", stringTransform("ToLower", strings.ToLower))
+	RegisterMethodContext(TypeString, "ToUpper", stringTransform("ToUpper", strings.ToUpper))
+	RegisterMethodContext(TypeString, "TrimSpace", stringTransform("TrimSpace", strings.TrimSpace))
+	RegisterMethodContext(TypeString, "Split", stringSplit)
+
+	// 注册数组方法
+	RegisterMethodContext(TypeList, "Len", collectionLen)
+	RegisterMethodContext(TypeList, "Contains", listContains)
+
+	// 注册map方法
+	RegisterMethodContext(TypeMap, "Len", collectionLen)
+	RegisterMethodContext(TypeMap, "Has", mapHas)
+}
+
+// RegisterMethod 注册值方法，kind 为接收者类型：string、int64、float、bool、list、map
+func RegisterMethod(kind string, name string, m Method) {
+	RegisterMethodContext(kind, name, func(ctx context.Context, recv interface{}, args []ast.Expr, data map[string]interface{}) interface{} {
+		return m(recv, args, data)
+	})
+}
+
+// RegisterMethodContext 注册支持上下文的值方法，kind 规则同 RegisterMethod
+func RegisterMethodContext(kind string, name string, m MethodContext) {
+	if _, ok := methodNameMap[kind]; !ok {
+		methodNameMap[kind] = make(map[string]MethodContext)
+	}
+	methodNameMap[kind][name] = m
+}
+
+// AllowMethods 允许表达式通过反射调用结构体 v 的指定导出方法，未在允许列表中的方法不可调用
+func AllowMethods(v interface{}, names ...string) {
+	typ := reflect.TypeOf(v)
+	if _, ok := allowedMethods[typ]; !ok {
+		allowedMethods[typ] = make(map[string]bool, len(names))
+	}
+	for _, name := range names {
+		allowedMethods[typ][name] = true
+	}
+}
+
+// kindOf 获取值的方法接收者类型
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return TypeString
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return TypeInt64
+	case float32, float64:
+		return TypeFloat
+	case bool:
+		return TypeBool
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Array:
+		return TypeList
+	case reflect.Map:
+		return TypeMap
+	}
+	return TypeObject
+}
+
+// calculateForMethod 计算值方法调用表达式，方法中的panic会被转换为错误返回
+func calculateForMethod(ctx context.Context, sel *ast.SelectorExpr, args []ast.Expr, data map[string]interface{}) (result interface{}) {
+	recv := EvalContext(ctx, sel.X, data)
+	if err, ok := recv.(error); ok {
+		return err
+	}
+	if recv == nil {
+		// 未定义的标识符更可能是未注册的命名空间函数，如 strings.HasPrefix
+		if ident, ok := sel.X.(*ast.Ident); ok {
+			if _, defined := data[ident.Name]; !defined {
+				return fmt.Errorf("unknown function %s.%s", ident.Name, sel.Sel.Name)
+			}
+		}
+		return fmt.Errorf("method %s called on nil value", sel.Sel.Name)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = &PanicError{Expr: sel.Sel.Name + "(...)", Value: r}
+		}
+	}()
+
+	kind := kindOf(recv)
+	if m, ok := methodNameMap[kind][sel.Sel.Name]; ok {
+		return m(ctx, recv, args, data)
+	}
+	if methods, ok := allowedMethods[reflect.TypeOf(recv)]; ok && methods[sel.Sel.Name] {
+		return callReflectMethod(ctx, recv, sel.Sel.Name, args, data)
+	}
+	return fmt.Errorf("%s method %s not support", kind, sel.Sel.Name)
+}
+
+// callReflectMethod 通过反射调用结构体方法，支持返回 (value) 或 (value, error)
+func callReflectMethod(ctx context.Context, recv interface{}, name string, args []ast.Expr, data map[string]interface{}) interface{} {
+	method := reflect.ValueOf(recv).MethodByName(name)
+	if !method.IsValid() {
+		return fmt.Errorf("%T method %s not found", recv, name)
+	}
+	typ := method.Type()
+	if typ.IsVariadic() || typ.NumOut() == 0 || typ.NumOut() > 2 {
+		return fmt.Errorf("%T method %s signature not support", recv, name)
+	}
+
+	values, err := evalArgs(ctx, name, args, data, typ.NumIn())
+	if err != nil {
+		return err
+	}
+	in := make([]reflect.Value, 0, len(values))
+	for i, v := range values {
+		arg, err := convertArg(v, typ.In(i))
+		if err != nil {
+			return fmt.Errorf("%T method %s params %d: %w", recv, name, i+1, err)
+		}
+		in = append(in, arg)
+	}
+
+	out := method.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		if err, ok := out[1].Interface().(error); ok {
+			return err
+		}
+	}
+	return out[0].Interface()
+}
+
+// convertArg 将参数转换为方法参数类型
+func convertArg(v interface{}, typ reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		return reflect.Zero(typ), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(typ) {
+		return rv, nil
+	}
+	if rv.Type().ConvertibleTo(typ) && rv.Kind() != reflect.String && typ.Kind() != reflect.String {
+		return rv.Convert(typ), nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot use %v (%T) as %s", v, v, typ)
+}
+
+// stringLen 字符串方法：s.Len()，返回字节长度
+func stringLen(ctx context.Context, recv interface{}, args []ast.Expr, data map[string]interface{}) interface{} {
+	if len(args) != 0 {
+		return errors.New("method Len expects 0 params")
+	}
+	return int64(len(recv.(string)))
+}
+
+// stringMethod 适配 func(s, t string) bool 形式的字符串方法
+func stringMethod(name string, f func(s, t string) bool) MethodContext {
+	return func(ctx context.Context, recv interface{}, args []ast.Expr, data map[string]interface{}) interface{} {
+		strs, err := evalStrings(ctx, name, args, data, 1)
+		if err != nil {
+			return err
+		}
+		return f(recv.(string), strs[0])
+	}
+}
+
+// stringTransform 适配 func(s string) string 形式的字符串方法
+func stringTransform(name string, f func(s string) string) MethodContext {
+	return func(ctx context.Context, recv interface{}, args []ast.Expr, data map[string]interface{}) interface{} {
+		if len(args) != 0 {
+			return fmt.Errorf("method %s expects 0 params", name)
+		}
+		return f(recv.(string))
+	}
+}
+
+// stringSplit 字符串方法：s.Split(sep)
+func stringSplit(ctx context.Context, recv interface{}, args []ast.Expr, data map[string]interface{}) interface{} {
+	strs, err := evalStrings(ctx, "Split", args, data, 1)
+	if err != nil {
+		return err
+	}
+	return strings.Split(recv.(string), strs[0])
+}
+
+// collectionLen 数组、map方法：x.Len()
+func collectionLen(ctx context.Context, recv interface{}, args []ast.Expr, data map[string]interface{}) interface{} {
+	if len(args) != 0 {
+		return errors.New("method Len expects 0 params")
+	}
+	return int64(reflect.ValueOf(recv).Len())
+}
+
+// listContains 数组方法：x.Contains(v)，数值按值比较
+func listContains(ctx context.Context, recv interface{}, args []ast.Expr, data map[string]interface{}) interface{} {
+	values, err := evalArgs(ctx, "Contains", args, data, 1)
+	if err != nil {
+		return err
+	}
+	list := reflect.ValueOf(recv)
+	for i := 0; i < list.Len(); i++ {
+		if looseEqual(list.Index(i).Interface(), values[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+// mapHas map方法：x.Has(key)
+func mapHas(ctx context.Context, recv interface{}, args []ast.Expr, data map[string]interface{}) interface{} {
+	values, err := evalArgs(ctx, "Has", args, data, 1)
+	if err != nil {
+		return err
+	}
+	m := reflect.ValueOf(recv)
+	key, err := convertArg(values[0], m.Type().Key())
+	if err != nil {
+		return err
+	}
+	return m.MapIndex(key).IsValid()
+}
+
+// looseEqual 判断两个值是否相等，不同整型、浮点型按数值比较
+func looseEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if _, ok := a.(string); ok {
+		return false
+	}
+	if _, ok := b.(string); ok {
+		return false
+	}
+	x, err := toNumber(a)
+	if err != nil {
+		return false
+	}
+	y, err := toNumber(b)
+	if err != nil {
+		return false
+	}
+	if !x.isFloat && !y.isFloat {
+		return x.i == y.i
+	}
+	return x.float() == y.float()
+}
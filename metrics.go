@@ -0,0 +1,183 @@
+package goparser
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets 默认的耗时直方图桶上界
+var DefaultLatencyBuckets = []time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// Histogram 耗时直方图
+type Histogram struct {
+	Bounds []time.Duration `json:"bounds"` // 桶上界，升序
+	Counts []int64         `json:"counts"` // 各桶计数，耗时不超过对应上界且大于上一上界；最后一个桶为超出全部上界的计数
+	Count  int64           `json:"count"`  // 总次数
+	Sum    time.Duration   `json:"sum"`    // 总耗时
+	Max    time.Duration   `json:"max"`    // 最大耗时
+}
+
+// newHistogram 创建直方图
+func newHistogram(bounds []time.Duration) Histogram {
+	return Histogram{Bounds: bounds, Counts: make([]int64, len(bounds)+1)}
+}
+
+// observe 记录一次耗时
+func (h *Histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Bounds), func(i int) bool { return d <= h.Bounds[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+	if d > h.Max {
+		h.Max = d
+	}
+}
+
+// Mean 平均耗时
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// clone 复制直方图
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]int64(nil), h.Counts...)
+	return h
+}
+
+// RuleStats 规则的计算统计
+type RuleStats struct {
+	Evals   int64     `json:"evals"`   // 计算次数
+	Matched int64     `json:"matched"` // 匹配次数
+	Errors  int64     `json:"errors"`  // 出错次数
+	Latency Histogram `json:"latency"` // 计算耗时
+}
+
+// FuncStats 函数的调用统计
+type FuncStats struct {
+	Calls   int64     `json:"calls"`   // 调用次数
+	Errors  int64     `json:"errors"`  // 返回error的次数
+	Latency Histogram `json:"latency"` // 调用耗时
+}
+
+// MetricsSnapshot 统计数据快照，可直接序列化导出
+type MetricsSnapshot struct {
+	Rules map[string]RuleStats `json:"rules"` // 规则ID => 计算统计
+	Funcs map[string]FuncStats `json:"funcs"` // 函数名 => 调用统计
+	Vars  map[string]int64     `json:"vars"`  // 变量名 => 访问次数
+}
+
+// Metrics 在内存中汇总规则、函数及变量统计的观察者，通过 WithObserver 设置，通过 Snapshot 导出
+type Metrics struct {
+	BaseObserver
+
+	mu      sync.Mutex
+	buckets []time.Duration
+	rules   map[string]*RuleStats
+	funcs   map[string]*FuncStats
+	vars    map[string]int64
+}
+
+// NewMetrics 创建统计观察者，buckets 为耗时直方图的桶上界，为空时使用 DefaultLatencyBuckets
+func NewMetrics(buckets ...time.Duration) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	m := &Metrics{buckets: buckets}
+	m.Reset()
+	return m
+}
+
+// OnRuleEnd 实现 Observer 接口
+func (m *Metrics) OnRuleEnd(e RuleEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.rules[e.ID]
+	if !ok {
+		stats = &RuleStats{Latency: newHistogram(m.buckets)}
+		m.rules[e.ID] = stats
+	}
+	stats.Evals++
+	switch {
+	case e.Err != nil:
+		stats.Errors++
+	case e.Matched:
+		stats.Matched++
+	}
+	stats.Latency.observe(e.Duration)
+}
+
+// OnFuncCall 实现 Observer 接口
+func (m *Metrics) OnFuncCall(e FuncEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.funcs[e.Name]
+	if !ok {
+		stats = &FuncStats{Latency: newHistogram(m.buckets)}
+		m.funcs[e.Name] = stats
+	}
+	stats.Calls++
+	if _, isErr := e.Result.(error); isErr {
+		stats.Errors++
+	}
+	stats.Latency.observe(e.Duration)
+}
+
+// OnVarAccess 实现 Observer 接口
+func (m *Metrics) OnVarAccess(name string, value interface{}) {
+	m.mu.Lock()
+	m.vars[name]++
+	m.mu.Unlock()
+}
+
+// Snapshot 返回当前统计数据的副本
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := MetricsSnapshot{
+		Rules: make(map[string]RuleStats, len(m.rules)),
+		Funcs: make(map[string]FuncStats, len(m.funcs)),
+		Vars:  make(map[string]int64, len(m.vars)),
+	}
+	for id, stats := range m.rules {
+		v := *stats
+		v.Latency = stats.Latency.clone()
+		s.Rules[id] = v
+	}
+	for name, stats := range m.funcs {
+		v := *stats
+		v.Latency = stats.Latency.clone()
+		s.Funcs[name] = v
+	}
+	for name, n := range m.vars {
+		s.Vars[name] = n
+	}
+	return s
+}
+
+// Reset 清空统计数据
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rules = make(map[string]*RuleStats)
+	m.funcs = make(map[string]*FuncStats)
+	m.vars = make(map[string]int64)
+}
@@ -0,0 +1,98 @@
+package goparser
+
+import (
+	"context"
+	"go/ast"
+	"time"
+)
+
+// Observer 计算过程观察者，用于统计规则的调用次数、耗时及错误，追踪函数调用与变量访问。
+// 同一观察者可能被多个协程同时调用，实现需保证并发安全
+type Observer interface {
+	// OnRuleStart 规则开始计算，id 为规则集中的规则ID，单独计算的表达式以表达式本身作为ID
+	OnRuleStart(id, expr string)
+	// OnRuleEnd 规则计算结束
+	OnRuleEnd(e RuleEvent)
+	// OnFuncCall 函数调用结束，包括内置函数及自定义函数
+	OnFuncCall(e FuncEvent)
+	// OnVarAccess 访问变量，value 为变量的值（通过 Resolver 获取的变量为获取结果）
+	OnVarAccess(name string, value interface{})
+}
+
+// RuleEvent 规则计算结束事件
+type RuleEvent struct {
+	ID       string        // 规则ID
+	Expr     string        // 规则表达式
+	Duration time.Duration // 计算耗时
+	Matched  bool          // 是否匹配
+	Err      error         // 计算出错时的错误
+}
+
+// FuncEvent 函数调用事件
+type FuncEvent struct {
+	Name     string        // 函数名，如 in_array、strings.HasPrefix
+	Args     []string      // 参数表达式
+	Duration time.Duration // 调用耗时
+	Result   interface{}   // 返回值，出错时为error
+}
+
+// BaseObserver 空实现的观察者，可嵌入自定义观察者中，只实现关心的回调
+type BaseObserver struct{}
+
+// OnRuleStart 实现 Observer 接口
+func (BaseObserver) OnRuleStart(id, expr string) {}
+
+// OnRuleEnd 实现 Observer 接口
+func (BaseObserver) OnRuleEnd(e RuleEvent) {}
+
+// OnFuncCall 实现 Observer 接口
+func (BaseObserver) OnFuncCall(e FuncEvent) {}
+
+// OnVarAccess 实现 Observer 接口
+func (BaseObserver) OnVarAccess(name string, value interface{}) {}
+
+// WithObserver 为上下文设置观察者，使用该上下文的 Match、Program、RuleSet 及批量过滤均会通知观察者
+func WithObserver(ctx context.Context, o Observer) context.Context {
+	return withState(ctx, func(state *evalState) {
+		state.observer = o
+	})
+}
+
+// observerFrom 获取上下文中的观察者，未设置时返回nil
+func observerFrom(ctx context.Context) Observer {
+	if state := stateFrom(ctx); state != nil {
+		return state.observer
+	}
+	return nil
+}
+
+// observeRule 计算规则并通知观察者，未设置观察者时直接计算
+func observeRule(ctx context.Context, id, expr string, match func() (bool, error)) (bool, error) {
+	o := observerFrom(ctx)
+	if o == nil {
+		return match()
+	}
+
+	o.OnRuleStart(id, expr)
+	start := time.Now()
+	ok, err := match()
+	o.OnRuleEnd(RuleEvent{ID: id, Expr: expr, Duration: time.Since(start), Matched: ok, Err: err})
+	return ok, err
+}
+
+// observeFunc 调用函数并通知观察者，未设置观察者时直接调用
+func observeFunc(ctx context.Context, name string, args []ast.Expr, call func() interface{}) interface{} {
+	o := observerFrom(ctx)
+	if o == nil {
+		return call()
+	}
+
+	start := time.Now()
+	result := call()
+	e := FuncEvent{Name: name, Duration: time.Since(start), Result: result}
+	for _, arg := range args {
+		e.Args = append(e.Args, exprString(arg))
+	}
+	o.OnFuncCall(e)
+	return result
+}
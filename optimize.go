@@ -0,0 +1,230 @@
+package goparser
+
+import (
+	"go/ast"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Optimize 优化表达式并返回优化后的表达式：折叠常量子表达式，展开 &&、|| 链中多余的括号，
+// 消除布尔表达式的双重否定，化简 x == true、x != false 等与布尔常量的比较。编译后的表达式会自动优化
+func Optimize(expr string) (s string, err error) {
+	defer recoverPanic(&err, expr)
+
+	root, fset, err := parseExprWithFileSet(expr)
+	if err != nil {
+		return "", err
+	}
+	if err := validateExpr(root, fset); err != nil {
+		return "", err
+	}
+	return exprString(optimizeExpr(root)), nil
+}
+
+// optimizeExpr 优化语法树，返回新的语法树，原语法树不会被修改
+func optimizeExpr(root ast.Expr) ast.Expr {
+	return unparen(optimizeNode(root))
+}
+
+// optimizeNode 自底向上优化节点
+func optimizeNode(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.ParenExpr:
+		// 只保留二元、一元表达式外的括号，由上层节点按运算符优先级决定是否省略
+		switch x := optimizeNode(expr.X).(type) {
+		case *ast.BinaryExpr, *ast.UnaryExpr:
+			return &ast.ParenExpr{X: x}
+		default:
+			return x
+		}
+	case *ast.UnaryExpr:
+		x := optimizeNode(expr.X)
+		if expr.Op == token.NOT {
+			return negate(x)
+		}
+		return foldConst(&ast.UnaryExpr{Op: expr.Op, X: x})
+	case *ast.BinaryExpr:
+		x, y := optimizeNode(expr.X), optimizeNode(expr.Y)
+		if simplified, ok := simplifyBoolCompare(expr.Op, x, y); ok {
+			return simplified
+		}
+		if expr.Op == token.LAND || expr.Op == token.LOR {
+			var operands []ast.Expr
+			flattenChain(expr.Op, x, &operands)
+			flattenChain(expr.Op, y, &operands)
+			x = operands[0]
+			for _, operand := range operands[1 : len(operands)-1] {
+				x = binary(x, expr.Op, operand)
+			}
+			y = operands[len(operands)-1]
+		}
+		return foldConst(binary(x, expr.Op, y))
+	case *ast.CallExpr:
+		args := make([]ast.Expr, 0, len(expr.Args))
+		for _, arg := range expr.Args {
+			args = append(args, unparen(optimizeNode(arg)))
+		}
+		return &ast.CallExpr{Fun: expr.Fun, Lparen: expr.Lparen, Args: args, Ellipsis: expr.Ellipsis, Rparen: expr.Rparen}
+	case *ast.CompositeLit:
+		elts := make([]ast.Expr, 0, len(expr.Elts))
+		for _, elt := range expr.Elts {
+			elts = append(elts, unparen(optimizeNode(elt)))
+		}
+		return &ast.CompositeLit{Type: expr.Type, Lbrace: expr.Lbrace, Elts: elts, Rbrace: expr.Rbrace}
+	}
+	return expr
+}
+
+// binary 创建二元表达式，去除按运算符优先级可以省略的括号
+func binary(x ast.Expr, op token.Token, y ast.Expr) *ast.BinaryExpr {
+	return &ast.BinaryExpr{X: operand(x, op, true), Op: op, Y: operand(y, op, false)}
+}
+
+// operand 去除二元表达式操作数外可以省略的括号，左结合的运算符左侧操作数可省略同优先级的括号
+func operand(expr ast.Expr, op token.Token, left bool) ast.Expr {
+	paren, ok := expr.(*ast.ParenExpr)
+	if !ok {
+		return expr
+	}
+	switch x := paren.X.(type) {
+	case *ast.UnaryExpr:
+		return x
+	case *ast.BinaryExpr:
+		if x.Op.Precedence() > op.Precedence() || (left && x.Op.Precedence() == op.Precedence()) {
+			return x
+		}
+	}
+	return expr
+}
+
+// paren 二元表达式作为一元表达式的操作数时需要加括号
+func paren(expr ast.Expr) ast.Expr {
+	if _, ok := expr.(*ast.BinaryExpr); ok {
+		return &ast.ParenExpr{X: expr}
+	}
+	return expr
+}
+
+// flattenChain 展开同一运算符连接的 &&、|| 链
+func flattenChain(op token.Token, expr ast.Expr, out *[]ast.Expr) {
+	if bin, ok := unparen(expr).(*ast.BinaryExpr); ok && bin.Op == op {
+		flattenChain(op, bin.X, out)
+		flattenChain(op, bin.Y, out)
+		return
+	}
+	*out = append(*out, expr)
+}
+
+// negate 对表达式取反，布尔表达式的双重否定 !!x 化简为 x
+func negate(x ast.Expr) ast.Expr {
+	if inner, ok := unparen(x).(*ast.UnaryExpr); ok && inner.Op == token.NOT && isBoolExpr(inner.X) {
+		return inner.X
+	}
+	return foldConst(&ast.UnaryExpr{Op: token.NOT, X: paren(unparen(x))})
+}
+
+// simplifyBoolCompare 化简布尔表达式与布尔常量的比较，如 x == true => x，x != true => !x
+func simplifyBoolCompare(op token.Token, x, y ast.Expr) (ast.Expr, bool) {
+	if op != token.EQL && op != token.NEQ {
+		return nil, false
+	}
+	value, isConst := boolConst(y)
+	if !isConst {
+		value, isConst = boolConst(x)
+		x = y
+	}
+	if !isConst || !isBoolExpr(x) {
+		return nil, false
+	}
+	if value == (op == token.EQL) {
+		return x, true
+	}
+	return negate(x), true
+}
+
+// boolConst 布尔常量 true、false
+func boolConst(expr ast.Expr) (value bool, ok bool) {
+	if ident, isIdent := unparen(expr).(*ast.Ident); isIdent {
+		switch ident.Name {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// isBoolExpr 表达式的计算结果一定为布尔值（或错误）
+func isBoolExpr(expr ast.Expr) bool {
+	switch expr := unparen(expr).(type) {
+	case *ast.Ident:
+		_, ok := boolConst(expr)
+		return ok
+	case *ast.UnaryExpr:
+		return expr.Op == token.NOT
+	case *ast.BinaryExpr:
+		switch expr.Op {
+		case token.EQL, token.NEQ, token.LSS, token.GTR, token.LEQ, token.GEQ, token.LAND, token.LOR:
+			return true
+		}
+	}
+	return false
+}
+
+// isConstExpr 表达式只包含字面量及布尔常量
+func isConstExpr(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.Ident:
+		_, ok := boolConst(expr)
+		return ok
+	case *ast.ParenExpr:
+		return isConstExpr(expr.X)
+	case *ast.UnaryExpr:
+		return isConstExpr(expr.X)
+	case *ast.BinaryExpr:
+		return isConstExpr(expr.X) && isConstExpr(expr.Y)
+	}
+	return false
+}
+
+// foldConst 折叠常量表达式，计算出错时保留原表达式，由计算时返回错误
+func foldConst(expr ast.Expr) ast.Expr {
+	if !isConstExpr(expr) {
+		return expr
+	}
+	if lit, ok := constLit(Eval(expr, map[string]interface{}{})); ok {
+		return lit
+	}
+	return expr
+}
+
+// constLit 将常量值转换为字面量，转换后计算结果的类型保持不变
+func constLit(value interface{}) (ast.Expr, bool) {
+	switch v := value.(type) {
+	case bool:
+		return ast.NewIdent(strconv.FormatBool(v)), true
+	case int64:
+		// 最小值的字面量无法解析
+		if v == math.MinInt64 {
+			return nil, false
+		}
+		return &ast.BasicLit{Kind: token.INT, Value: strconv.FormatInt(v, 10)}, true
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, false
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return &ast.BasicLit{Kind: token.FLOAT, Value: s}, true
+	case string:
+		return &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(v)}, true
+	}
+	return nil, false
+}
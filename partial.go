@@ -0,0 +1,209 @@
+package goparser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+)
+
+// PartialResult 部分计算结果
+type PartialResult struct {
+	Decided  bool     // 结果已确定
+	Value    bool     // 结果已确定时的匹配结果
+	Residual string   // 结果未确定时的剩余表达式，只引用未知变量
+	Vars     []string // 剩余表达式引用的未知变量
+}
+
+// PartialEval 使用部分已知数据计算表达式：代入已知变量，折叠已确定的 && 与 || 分支，
+// 返回最终结果或只引用未知变量的剩余表达式。knownData 中不存在的变量视为未知，
+// 折叠分支时假定未知变量的条件计算结果为布尔值
+func PartialEval(expr string, knownData map[string]interface{}) (result *PartialResult, err error) {
+	defer recoverPanic(&err, expr)
+
+	root, fset, err := parseExprWithFileSet(expr)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateExpr(root, fset); err != nil {
+		return nil, err
+	}
+	if knownData == nil {
+		knownData = map[string]interface{}{}
+	}
+
+	p := &partialEvaluator{ctx: context.Background(), data: knownData}
+	v := p.eval(root)
+	if p.err != nil {
+		return nil, p.err
+	}
+	if v.residual == nil {
+		if err, ok := v.value.(error); ok {
+			return nil, err
+		}
+		matched, err := resultToBool(expr, v.value)
+		if err != nil {
+			return nil, err
+		}
+		return &PartialResult{Decided: true, Value: matched}, nil
+	}
+
+	residual := optimizeExpr(v.residual)
+	return &PartialResult{Residual: exprString(residual), Vars: inspectExpr(residual).vars}, nil
+}
+
+// partialValue 节点的部分计算结果，residual 为nil时 value 为已确定的值
+type partialValue struct {
+	value    interface{}
+	residual ast.Expr
+}
+
+// partialEvaluator 部分计算器
+type partialEvaluator struct {
+	ctx  context.Context
+	data map[string]interface{}
+	err  error // 已知值无法代入剩余表达式时的错误
+}
+
+// eval 递归计算节点，已知部分计算为值，其余部分保留为剩余表达式
+func (p *partialEvaluator) eval(expr ast.Expr) partialValue {
+	switch expr := expr.(type) {
+	case *ast.ParenExpr:
+		v := p.eval(expr.X)
+		if v.residual != nil {
+			v.residual = &ast.ParenExpr{X: v.residual}
+		}
+		return v
+	case *ast.Ident:
+		if _, ok := boolConst(expr); !ok {
+			if _, known := p.data[expr.Name]; !known {
+				return partialValue{residual: expr}
+			}
+		}
+	case *ast.BinaryExpr:
+		x := p.eval(expr.X)
+		if x.residual == nil {
+			if b, ok := shortCircuit(x.value, expr.Op); ok {
+				return partialValue{value: b}
+			}
+		}
+		y := p.eval(expr.Y)
+		if x.residual == nil && y.residual == nil {
+			return partialValue{value: evalBinary(x.value, y.value, expr.Op)}
+		}
+		if expr.Op == token.LAND || expr.Op == token.LOR {
+			if v, ok := p.foldLogic(expr.Op, x, y); ok {
+				return v
+			}
+		}
+		return partialValue{residual: &ast.BinaryExpr{X: p.expr(x), Op: expr.Op, Y: p.expr(y)}}
+	case *ast.UnaryExpr:
+		x := p.eval(expr.X)
+		if x.residual == nil {
+			return partialValue{value: evalUnary(expr, x.value)}
+		}
+		return partialValue{residual: &ast.UnaryExpr{Op: expr.Op, X: x.residual}}
+	case *ast.CallExpr, *ast.CompositeLit:
+		// 引用的变量均已知时直接计算，否则代入已知变量
+		if p.unknownVars(expr) {
+			return partialValue{residual: p.substitute(expr)}
+		}
+	}
+	return partialValue{value: EvalContext(p.ctx, expr, p.data)}
+}
+
+// foldLogic 折叠 && 与 || 中已确定的分支：false && x 为false，true && x 为x，true || x 为true，false || x 为x
+func (p *partialEvaluator) foldLogic(op token.Token, x, y partialValue) (partialValue, bool) {
+	known, other := x, y
+	if known.residual != nil {
+		known, other = y, x
+	}
+	b, ok := known.value.(bool)
+	if !ok {
+		return partialValue{}, false
+	}
+	if b == (op == token.LOR) {
+		return partialValue{value: b}, true
+	}
+	return other, true
+}
+
+// unknownVars 节点是否引用了未知变量
+func (p *partialEvaluator) unknownVars(expr ast.Expr) bool {
+	for _, name := range inspectExpr(expr).vars {
+		if _, known := p.data[name]; !known {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute 将函数调用、数组字面量中的已知变量替换为字面量
+func (p *partialEvaluator) substitute(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.CallExpr:
+		call := *expr
+		if sel, ok := expr.Fun.(*ast.SelectorExpr); ok {
+			// 值方法的接收者可能为已知变量
+			if name, isName := selectorName(sel); !isName || funcNameMap[name] == nil {
+				call.Fun = &ast.SelectorExpr{X: p.expr(p.eval(sel.X)), Sel: sel.Sel}
+			}
+		}
+		call.Args = make([]ast.Expr, 0, len(expr.Args))
+		for _, arg := range expr.Args {
+			call.Args = append(call.Args, p.substituteArg(arg))
+		}
+		return &call
+	case *ast.CompositeLit:
+		lit := *expr
+		lit.Elts = make([]ast.Expr, 0, len(expr.Elts))
+		for _, elt := range expr.Elts {
+			lit.Elts = append(lit.Elts, p.substituteArg(elt))
+		}
+		return &lit
+	}
+	return expr
+}
+
+// substituteArg 替换参数中的已知变量，数组字面量保持字面量形式
+func (p *partialEvaluator) substituteArg(arg ast.Expr) ast.Expr {
+	if lit, ok := arg.(*ast.CompositeLit); ok {
+		return p.substitute(lit)
+	}
+	return p.expr(p.eval(arg))
+}
+
+// expr 将部分计算结果转换为表达式，已知值转换为字面量
+func (p *partialEvaluator) expr(v partialValue) ast.Expr {
+	if v.residual != nil {
+		return v.residual
+	}
+	value := v.value
+	if err, ok := value.(error); ok {
+		if p.err == nil {
+			p.err = err
+		}
+		return ast.NewIdent("nil")
+	}
+	// 各类数值统一转为int64、float64后再转换为字面量
+	switch value.(type) {
+	case int, int8, int16, int32, uint, uint8, uint16, uint32, uint64, float32, json.Number:
+		n, err := toNumber(value)
+		if err != nil {
+			if p.err == nil {
+				p.err = err
+			}
+			return ast.NewIdent("nil")
+		}
+		value = n.value()
+	}
+	lit, ok := constLit(value)
+	if !ok {
+		if p.err == nil {
+			p.err = fmt.Errorf("known value %v (%T) cannot be substituted into residual expression", v.value, v.value)
+		}
+		return ast.NewIdent("nil")
+	}
+	return lit
+}
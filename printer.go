@@ -0,0 +1,78 @@
+package goparser
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"strings"
+)
+
+// exprString 将语法树还原为规范化的表达式字符串：二元运算符两侧保留一个空格，参数以 ", " 分隔
+func exprString(expr ast.Expr) string {
+	var b strings.Builder
+	writeExpr(&b, expr)
+	return b.String()
+}
+
+// writeExpr 将语法树节点写入字符串
+func writeExpr(b *strings.Builder, expr ast.Expr) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		b.WriteString(expr.Name)
+	case *ast.BasicLit:
+		b.WriteString(expr.Value)
+	case *ast.ParenExpr:
+		b.WriteString("(")
+		writeExpr(b, expr.X)
+		b.WriteString(")")
+	case *ast.UnaryExpr:
+		b.WriteString(expr.Op.String())
+		writeExpr(b, expr.X)
+	case *ast.BinaryExpr:
+		writeExpr(b, expr.X)
+		b.WriteString(" ")
+		b.WriteString(expr.Op.String())
+		b.WriteString(" ")
+		writeExpr(b, expr.Y)
+	case *ast.CallExpr:
+		writeExpr(b, expr.Fun)
+		b.WriteString("(")
+		writeExprList(b, expr.Args)
+		b.WriteString(")")
+	case *ast.SelectorExpr:
+		writeExpr(b, expr.X)
+		b.WriteString(".")
+		b.WriteString(expr.Sel.Name)
+	case *ast.CompositeLit:
+		if expr.Type != nil {
+			writeExpr(b, expr.Type)
+		}
+		b.WriteString("{")
+		writeExprList(b, expr.Elts)
+		b.WriteString("}")
+	case *ast.ArrayType:
+		b.WriteString("[")
+		if expr.Len != nil {
+			writeExpr(b, expr.Len)
+		}
+		b.WriteString("]")
+		writeExpr(b, expr.Elt)
+	default:
+		// 规则语言之外的语法使用原生printer输出
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, token.NewFileSet(), expr); err == nil {
+			b.Write(buf.Bytes())
+		}
+	}
+}
+
+// writeExprList 写入以 ", " 分隔的表达式列表
+func writeExprList(b *strings.Builder, list []ast.Expr) {
+	for i, expr := range list {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeExpr(b, expr)
+	}
+}
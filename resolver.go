@@ -0,0 +1,103 @@
+package goparser
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Resolver 变量解析器，按需获取数据中不存在的变量，如从缓存、特征库或数据库中读取。
+// 变量不存在时返回false，获取出错时返回error
+type Resolver interface {
+	Resolve(name string) (interface{}, bool, error)
+}
+
+// ResolverFunc 函数形式的变量解析器
+type ResolverFunc func(name string) (interface{}, bool, error)
+
+// Resolve 实现 Resolver 接口
+func (f ResolverFunc) Resolve(name string) (interface{}, bool, error) {
+	return f(name)
+}
+
+// resolveCache 变量解析器及已解析的变量
+type resolveCache struct {
+	resolver Resolver
+	mu       sync.Mutex
+	values   map[string]interface{} // 变量 => 值，不存在时为nil，获取出错时为error
+}
+
+// WithResolver 为上下文设置变量解析器。计算时优先使用数据中的变量，数据中不存在的变量在表达式实际引用时才通过解析器获取，
+// 同一变量只获取一次。已解析的变量在使用同一上下文的多次计算间复用，通常每次计算创建一个新的上下文；
+// FilterSlice、FilterIter 等批量过滤接口为每条记录单独解析变量
+func WithResolver(ctx context.Context, r Resolver) context.Context {
+	return withState(ctx, func(state *evalState) {
+		state.resolve = &resolveCache{resolver: r, values: make(map[string]interface{})}
+	})
+}
+
+// withRecordResolver 为单条记录派生上下文，沿用上下文中的变量解析器，已解析的变量不在记录间复用
+func withRecordResolver(ctx context.Context) context.Context {
+	state := stateFrom(ctx)
+	if state == nil || state.resolve == nil {
+		return ctx
+	}
+	return withState(ctx, func(state *evalState) {
+		state.resolve = &resolveCache{resolver: state.resolve.resolver, values: make(map[string]interface{})}
+	})
+}
+
+// MatchResolver 使用变量解析器获取变量并完成表达式匹配，变量只在表达式引用时获取
+func MatchResolver(ctx context.Context, expr string, r Resolver) (bool, error) {
+	return MatchContext(WithResolver(ctx, r), expr, map[string]interface{}{})
+}
+
+// MatchResolver 使用变量解析器获取变量并完成匹配，变量只在表达式引用时获取
+func (p *Program) MatchResolver(ctx context.Context, r Resolver) (bool, error) {
+	return p.MatchContext(WithResolver(ctx, r), map[string]interface{}{})
+}
+
+// lookupVar 获取变量的值，数据中不存在时通过上下文中的变量解析器获取
+func lookupVar(ctx context.Context, data map[string]interface{}, name string) interface{} {
+	return stateFrom(ctx).lookupVar(data, name)
+}
+
+// lookupVar 获取变量的值并通知观察者
+func (s *evalState) lookupVar(data map[string]interface{}, name string) interface{} {
+	v, ok := data[name]
+	if !ok {
+		v = s.resolveVar(name)
+	}
+	if s != nil && s.observer != nil {
+		s.observer.OnVarAccess(name, v)
+	}
+	return v
+}
+
+// resolveVar 通过变量解析器获取变量，未设置解析器或变量不存在时返回nil
+func (s *evalState) resolveVar(name string) interface{} {
+	if s == nil || s.resolve == nil {
+		return nil
+	}
+	c := s.resolve
+
+	c.mu.Lock()
+	v, ok := c.values[name]
+	c.mu.Unlock()
+	if ok {
+		return v
+	}
+
+	v, found, err := c.resolver.Resolve(name)
+	switch {
+	case err != nil:
+		v = fmt.Errorf("resolve %s: %w", name, err)
+	case !found:
+		v = nil
+	}
+
+	c.mu.Lock()
+	c.values[name] = v
+	c.mu.Unlock()
+	return v
+}
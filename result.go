@@ -0,0 +1,136 @@
+package goparser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// 结果类型名称定义
+const (
+	ResultBool   = "bool"
+	ResultInt    = "int64"
+	ResultFloat  = "float64"
+	ResultString = "string"
+)
+
+// ResultTypeError 表达式计算结果与期望类型不匹配
+type ResultTypeError struct {
+	Expr  string      // 表达式
+	Want  string      // 期望类型
+	Value interface{} // 实际计算结果
+}
+
+// Error 实现error接口
+func (e *ResultTypeError) Error() string {
+	return fmt.Sprintf("expr %q result %v (%T) is not %s", e.Expr, e.Value, e.Value, e.Want)
+}
+
+// EvalValue 计算表达式并返回原始结果，适用于计分公式、计算字段等非布尔表达式
+func EvalValue(expr string, data map[string]interface{}) (interface{}, error) {
+	return EvalValueContext(context.Background(), expr, data)
+}
+
+// EvalValueContext 在上下文中计算表达式并返回原始结果
+func EvalValueContext(ctx context.Context, expr string, data map[string]interface{}) (value interface{}, err error) {
+	defer recoverPanic(&err, expr)
+	if expr == "" {
+		return nil, errors.New("empty expression")
+	}
+	parseExpr, err := parseExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	result := evalWithContext(ctx, expr, parseExpr, data)
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	return result, nil
+}
+
+// EvalBool 计算表达式并返回bool结果
+func EvalBool(expr string, data map[string]interface{}) (bool, error) {
+	result, err := EvalValue(expr, data)
+	if err != nil {
+		return false, err
+	}
+	return resultToBool(expr, result)
+}
+
+// EvalInt 计算表达式并返回int64结果，小数部分不为零的浮点数视为类型不匹配
+func EvalInt(expr string, data map[string]interface{}) (int64, error) {
+	result, err := EvalValue(expr, data)
+	if err != nil {
+		return 0, err
+	}
+	return resultToInt(expr, result)
+}
+
+// EvalFloat 计算表达式并返回float64结果
+func EvalFloat(expr string, data map[string]interface{}) (float64, error) {
+	result, err := EvalValue(expr, data)
+	if err != nil {
+		return 0, err
+	}
+	return resultToFloat(expr, result)
+}
+
+// EvalString 计算表达式并返回string结果
+func EvalString(expr string, data map[string]interface{}) (string, error) {
+	result, err := EvalValue(expr, data)
+	if err != nil {
+		return "", err
+	}
+	return resultToString(expr, result)
+}
+
+// resultToBool 计算结果安全转换为bool
+func resultToBool(expr string, result interface{}) (bool, error) {
+	if b, ok := result.(bool); ok {
+		return b, nil
+	}
+	return false, &ResultTypeError{Expr: expr, Want: ResultBool, Value: result}
+}
+
+// resultToInt 计算结果安全转换为int64
+func resultToInt(expr string, result interface{}) (int64, error) {
+	if _, ok := result.(string); ok {
+		return 0, &ResultTypeError{Expr: expr, Want: ResultInt, Value: result}
+	}
+	n, err := toNumber(result)
+	if err != nil {
+		return 0, &ResultTypeError{Expr: expr, Want: ResultInt, Value: result}
+	}
+	if !n.isFloat {
+		return n.i, nil
+	}
+	if n.f != math.Trunc(n.f) || n.f < math.MinInt64 || n.f >= math.MaxInt64 {
+		return 0, &ResultTypeError{Expr: expr, Want: ResultInt, Value: result}
+	}
+	return int64(n.f), nil
+}
+
+// resultToFloat 计算结果安全转换为float64
+func resultToFloat(expr string, result interface{}) (float64, error) {
+	if _, ok := result.(string); ok {
+		return 0, &ResultTypeError{Expr: expr, Want: ResultFloat, Value: result}
+	}
+	n, err := toNumber(result)
+	if err != nil {
+		return 0, &ResultTypeError{Expr: expr, Want: ResultFloat, Value: result}
+	}
+	return n.float(), nil
+}
+
+// resultToString 计算结果安全转换为string
+func resultToString(expr string, result interface{}) (string, error) {
+	if s, ok := result.(string); ok {
+		return s, nil
+	}
+	return "", &ResultTypeError{Expr: expr, Want: ResultString, Value: result}
+}
@@ -0,0 +1,283 @@
+package goparser
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Rule 规则集中的规则
+type Rule struct {
+	ID       string   // 规则ID，在规则集中唯一
+	Priority int      // 优先级，数值越大优先级越高
+	Program  *Program // 编译后的表达式
+
+	seq int // 添加顺序
+}
+
+// RuleError 单条规则计算出错
+type RuleError struct {
+	ID  string
+	Err error
+}
+
+// Error 实现error接口
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("rule %s: %v", e.ID, e.Err)
+}
+
+// Unwrap 支持 errors.Is、errors.As
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// RuleSetError 规则集计算过程中出错的规则，出错的规则视为不匹配，不影响其他规则的计算
+type RuleSetError struct {
+	Errors []*RuleError
+}
+
+// Error 实现error接口
+func (e *RuleSetError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d rules failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// RuleSet 规则集，对同一份数据批量计算多条规则，规则间相同的子表达式在单次计算中只计算一次。
+// 规则顶层 && 连接的等值条件及 in_array 条件会建立哈希索引，索引条件不可能成立的规则不参与计算。
+// 规则集可被多个协程并发使用
+type RuleSet struct {
+	mu         sync.RWMutex
+	rules      []*Rule // 按添加顺序
+	byPriority []*Rule // 按优先级降序，优先级相同时按添加顺序
+	ids        map[string]bool
+
+	// 公共子表达式统计
+	nodes  map[string][]ast.Expr // 规范化表达式 => 语法树节点
+	shared map[ast.Expr]string   // 出现两次及以上的子表达式节点 => 规范化表达式
+
+	// 规则索引
+	index     map[string]*fieldIndex // 变量名 => 索引
+	unindexed []*Rule                // 未建立索引的规则，总是参与计算
+}
+
+// NewRuleSet 创建规则集
+func NewRuleSet() *RuleSet {
+	return &RuleSet{
+		ids:    make(map[string]bool),
+		nodes:  make(map[string][]ast.Expr),
+		shared: make(map[ast.Expr]string),
+		index:  make(map[string]*fieldIndex),
+	}
+}
+
+// Add 编译表达式并添加到规则集
+func (rs *RuleSet) Add(id string, priority int, expr string, opts ...CompileOption) error {
+	p, err := Compile(expr, opts...)
+	if err != nil {
+		return &RuleError{ID: id, Err: err}
+	}
+	return rs.AddProgram(id, priority, p)
+}
+
+// AddProgram 将编译后的表达式添加到规则集
+func (rs *RuleSet) AddProgram(id string, priority int, p *Program) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.ids[id] {
+		return fmt.Errorf("rule %s already exists", id)
+	}
+	rule := &Rule{ID: id, Priority: priority, Program: p, seq: len(rs.rules)}
+	rs.ids[id] = true
+	rs.rules = append(rs.rules, rule)
+
+	// 插入到同优先级规则之后，保持优先级降序
+	i := sort.Search(len(rs.byPriority), func(i int) bool {
+		return rs.byPriority[i].Priority < priority
+	})
+	rs.byPriority = append(rs.byPriority, nil)
+	copy(rs.byPriority[i+1:], rs.byPriority[i:])
+	rs.byPriority[i] = rule
+
+	if field, values, ok := indexPredicate(p.root); ok {
+		idx := rs.index[field]
+		if idx == nil {
+			idx = &fieldIndex{}
+			rs.index[field] = idx
+		}
+		idx.add(rule, values)
+	} else {
+		rs.unindexed = append(rs.unindexed, rule)
+	}
+
+	rs.collectShared(p.root)
+	return nil
+}
+
+// candidates 通过索引筛选可能匹配的规则，byPriority 表示按优先级排序，否则按添加顺序排序。
+// 索引字段只从数据中读取，不通过变量解析器获取、不通知观察者：数据中不存在的字段对应的规则均作为候选规则，
+// 在计算时按需获取变量
+func (rs *RuleSet) candidates(data map[string]interface{}, byPriority bool) []*Rule {
+	if len(rs.index) == 0 {
+		if byPriority {
+			return rs.byPriority
+		}
+		return rs.rules
+	}
+
+	rules := append([]*Rule(nil), rs.unindexed...)
+	for field, idx := range rs.index {
+		rules = idx.lookup(data[field], rules)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if byPriority && rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority
+		}
+		return rules[i].seq < rules[j].seq
+	})
+
+	// 同一规则可能以多个取值命中索引，排序后去重
+	n := 0
+	for i, rule := range rules {
+		if i == 0 || rule != rules[n-1] {
+			rules[n] = rule
+			n++
+		}
+	}
+	return rules[:n]
+}
+
+// collectShared 统计子表达式，出现两次及以上的子表达式计入公共子表达式
+func (rs *RuleSet) collectShared(root ast.Expr) {
+	ast.Inspect(root, func(node ast.Node) bool {
+		expr, ok := node.(ast.Expr)
+		if !ok {
+			return true
+		}
+		switch expr.(type) {
+		case *ast.Ident, *ast.BasicLit, *ast.ParenExpr, *ast.CompositeLit, *ast.SelectorExpr:
+			// 变量、字面量等节点计算开销小于缓存查询，无需复用
+			return true
+		}
+
+		key := exprString(expr)
+		rs.nodes[key] = append(rs.nodes[key], expr)
+		switch n := len(rs.nodes[key]); {
+		case n == 2:
+			for _, node := range rs.nodes[key] {
+				rs.shared[node] = key
+			}
+		case n > 2:
+			rs.shared[expr] = key
+		}
+		return true
+	})
+}
+
+// Len 返回规则数量
+func (rs *RuleSet) Len() int {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return len(rs.rules)
+}
+
+// Rules 按添加顺序返回全部规则
+func (rs *RuleSet) Rules() []*Rule {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return append([]*Rule(nil), rs.rules...)
+}
+
+// MatchAll 按添加顺序返回全部匹配的规则
+func (rs *RuleSet) MatchAll(data map[string]interface{}) ([]*Rule, error) {
+	return rs.MatchAllContext(context.Background(), data)
+}
+
+// MatchAllContext 在上下文中按添加顺序返回全部匹配的规则，
+// 出错的规则视为不匹配，并通过 *RuleSetError 返回
+func (rs *RuleSet) MatchAllContext(ctx context.Context, data map[string]interface{}) ([]*Rule, error) {
+	return rs.match(ctx, data, false, false)
+}
+
+// MatchFirst 按添加顺序返回第一条匹配的规则，无匹配时返回nil
+func (rs *RuleSet) MatchFirst(data map[string]interface{}) (*Rule, error) {
+	return rs.MatchFirstContext(context.Background(), data)
+}
+
+// MatchFirstContext 在上下文中按添加顺序返回第一条匹配的规则
+func (rs *RuleSet) MatchFirstContext(ctx context.Context, data map[string]interface{}) (*Rule, error) {
+	return firstRule(rs.match(ctx, data, false, true))
+}
+
+// MatchBest 返回优先级最高的匹配规则，优先级相同时返回先添加的规则，无匹配时返回nil
+func (rs *RuleSet) MatchBest(data map[string]interface{}) (*Rule, error) {
+	return rs.MatchBestContext(context.Background(), data)
+}
+
+// MatchBestContext 在上下文中返回优先级最高的匹配规则
+func (rs *RuleSet) MatchBestContext(ctx context.Context, data map[string]interface{}) (*Rule, error) {
+	return firstRule(rs.match(ctx, data, true, true))
+}
+
+// firstRule 取匹配结果中的第一条规则
+func firstRule(rules []*Rule, err error) (*Rule, error) {
+	if len(rules) == 0 {
+		return nil, err
+	}
+	return rules[0], err
+}
+
+// match 计算规则集，byPriority 表示按优先级顺序计算，first 表示找到第一条匹配规则后停止
+func (rs *RuleSet) match(ctx context.Context, data map[string]interface{}, byPriority, first bool) ([]*Rule, error) {
+	// 空数据默认匹配失败
+	if data == nil {
+		return nil, nil
+	}
+
+	var matched []*Rule
+	var errs []*RuleError
+	result := runWithContext(ctx, "ruleset", func() interface{} {
+		rs.mu.RLock()
+		defer rs.mu.RUnlock()
+
+		rules := rs.candidates(data, byPriority)
+		ctx := withState(ctx, func(state *evalState) {
+			state.shared = rs.shared
+			state.cache = make(map[string]interface{})
+		})
+
+		for _, rule := range rules {
+			ok, err := observeRule(ctx, rule.ID, rule.Program.expr, func() (bool, error) {
+				return rule.Program.matchState(ctx, data)
+			})
+			if err != nil {
+				// 上下文结束时终止计算
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				errs = append(errs, &RuleError{ID: rule.ID, Err: err})
+				continue
+			}
+			if ok {
+				matched = append(matched, rule)
+				if first {
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return matched, &RuleSetError{Errors: errs}
+	}
+	return matched, nil
+}
@@ -0,0 +1,325 @@
+// Package server 通过HTTP接口以JSON提供规则计算服务，供非Go服务复用相同的规则语义
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	goparser "github.com/BeCrafter/go-parser"
+)
+
+// 错误码定义
+const (
+	CodeInvalidRequest   = "invalid_request"    // 请求格式错误
+	CodePayloadTooLarge  = "payload_too_large"  // 请求体超出大小限制
+	CodeMethodNotAllowed = "method_not_allowed" // 请求方法错误
+	CodeNotFound         = "not_found"          // 接口不存在
+	CodeCompileError     = "compile_error"      // 表达式解析、校验或编译失败
+	CodeEvalError        = "eval_error"         // 表达式计算失败
+)
+
+// Error 错误响应
+type Error struct {
+	Code    string `json:"code"`    // 错误码
+	Message string `json:"message"` // 错误说明
+}
+
+// Request 请求体，各接口使用其中的部分字段
+type Request struct {
+	Expr string                 `json:"expr,omitempty"` // 表达式
+	Data map[string]interface{} `json:"data,omitempty"` // 计算数据
+	Tree map[string]interface{} `json:"tree,omitempty"` // Expression 使用的JSON条件树
+}
+
+// Response 响应体，各接口返回其中的部分字段
+type Response struct {
+	Result  interface{} `json:"result,omitempty"`  // /eval 的计算结果
+	Matched *bool       `json:"matched,omitempty"` // /match 的匹配结果
+	Valid   *bool       `json:"valid,omitempty"`   // /validate 的校验结果
+	Issues  []string    `json:"issues,omitempty"`  // /validate 的问题列表
+	Expr    string      `json:"expr,omitempty"`    // /expression 生成的表达式
+	Fields  []string    `json:"fields,omitempty"`  // /fields 导出的变量
+	Error   *Error      `json:"error,omitempty"`   // 出错时的错误
+}
+
+// Option 服务选项函数
+type Option func(s *Server)
+
+// MaxBodyBytes 请求体最大字节数，默认1MB
+func MaxBodyBytes(n int64) Option {
+	return func(s *Server) { s.maxBody = n }
+}
+
+// CacheSize 编译结果缓存的表达式个数，默认1000，为0时不缓存
+func CacheSize(n int) Option {
+	return func(s *Server) { s.cacheSize = n }
+}
+
+// Timeout 单次计算的超时时间，默认不限制
+func Timeout(d time.Duration) Option {
+	return func(s *Server) { s.timeout = d }
+}
+
+// CompileOptions 编译表达式时使用的编译选项，用于限制不可信规则的复杂度
+func CompileOptions(opts ...goparser.CompileOption) Option {
+	return func(s *Server) { s.compileOpts = append(s.compileOpts, opts...) }
+}
+
+// Server 规则计算服务，实现 http.Handler：
+//
+//	POST /eval        {"expr", "data"} => {"result"}
+//	POST /match       {"expr", "data"} => {"matched"}
+//	POST /validate    {"expr"}         => {"valid", "issues"}
+//	POST /expression  {"tree"}         => {"expr"}
+//	POST /fields      {"expr"} 或 {"tree"} => {"fields"}
+//
+// 出错时返回对应的HTTP状态码及 {"error": {"code", "message"}}
+type Server struct {
+	maxBody     int64
+	cacheSize   int
+	timeout     time.Duration
+	compileOpts []goparser.CompileOption
+
+	mux   *http.ServeMux
+	cache *goparser.ProgramCache
+}
+
+// New 创建规则计算服务
+func New(opts ...Option) *Server {
+	s := &Server{maxBody: 1 << 20, cacheSize: 1000}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.cache = goparser.NewProgramCache(s.cacheSize)
+
+	s.mux = http.NewServeMux()
+	s.mux.HandleFunc("/eval", s.handle(s.eval))
+	s.mux.HandleFunc("/match", s.handle(s.match))
+	s.mux.HandleFunc("/validate", s.handle(s.validate))
+	s.mux.HandleFunc("/expression", s.handle(s.expression))
+	s.mux.HandleFunc("/fields", s.handle(s.fields))
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, CodeNotFound, fmt.Sprintf("%s not found", r.URL.Path))
+	})
+	return s
+}
+
+// ServeHTTP 实现 http.Handler 接口
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// apiError 带HTTP状态码的接口错误
+type apiError struct {
+	status int
+	code   string
+	err    error
+}
+
+// Error 实现error接口
+func (e *apiError) Error() string {
+	return e.err.Error()
+}
+
+// handler 接口处理函数
+type handler func(ctx context.Context, req *Request) (*Response, error)
+
+// handle 校验请求方法、解析请求体并输出响应
+func (s *Server) handle(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not allowed")
+			return
+		}
+
+		req, err := s.decode(r)
+		if err != nil {
+			if errors.Is(err, errTooLarge) {
+				writeError(w, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("request body exceeds %d bytes", s.maxBody))
+				return
+			}
+			writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+			return
+		}
+
+		ctx := r.Context()
+		if s.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.timeout)
+			defer cancel()
+		}
+		resp, err := h(ctx, req)
+		if err != nil {
+			var e *apiError
+			if !errors.As(err, &e) {
+				e = &apiError{status: http.StatusInternalServerError, code: CodeEvalError, err: err}
+			}
+			writeError(w, e.status, e.code, e.err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// errTooLarge 请求体超出大小限制
+var errTooLarge = errors.New("request body too large")
+
+// decode 解析请求体，数据中的整数解析为int64、小数解析为float64
+func (s *Server) decode(r *http.Request) (*Request, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(io.LimitReader(r.Body, s.maxBody+1)); err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) > s.maxBody {
+		return nil, errTooLarge
+	}
+
+	dec := json.NewDecoder(&buf)
+	dec.UseNumber()
+	dec.DisallowUnknownFields()
+	req := &Request{}
+	if err := dec.Decode(req); err != nil {
+		return nil, fmt.Errorf("decode request: %w", err)
+	}
+	for k, v := range req.Data {
+		req.Data[k] = goparser.NormalizeJSON(v)
+	}
+	return req, nil
+}
+
+// eval 计算表达式
+func (s *Server) eval(ctx context.Context, req *Request) (*Response, error) {
+	p, err := s.compile(req.Expr)
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.EvalContext(ctx, req.Data)
+	if err != nil {
+		return nil, evalError(err)
+	}
+	return &Response{Result: v}, nil
+}
+
+// match 匹配表达式
+func (s *Server) match(ctx context.Context, req *Request) (*Response, error) {
+	p, err := s.compile(req.Expr)
+	if err != nil {
+		return nil, err
+	}
+	data := req.Data
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	ok, err := p.MatchContext(ctx, data)
+	if err != nil {
+		return nil, evalError(err)
+	}
+	return &Response{Matched: &ok}, nil
+}
+
+// validate 校验表达式，校验结果通过响应返回
+func (s *Server) validate(ctx context.Context, req *Request) (*Response, error) {
+	if req.Expr == "" {
+		return nil, invalidRequest("missing expr")
+	}
+	_, err := s.compile(req.Expr)
+	var e *apiError
+	if errors.As(err, &e) && e.code != CodeCompileError {
+		return nil, err
+	}
+
+	valid := err == nil
+	resp := &Response{Valid: &valid}
+	if err != nil {
+		resp.Issues = goparser.ErrorIssues(e.err)
+	}
+	return resp, nil
+}
+
+// expression 将JSON条件树转换为表达式
+func (s *Server) expression(ctx context.Context, req *Request) (*Response, error) {
+	if req.Tree == nil {
+		return nil, invalidRequest("missing tree")
+	}
+	expr, err := goparser.Expression(req.Tree)
+	if err != nil {
+		return nil, &apiError{status: http.StatusUnprocessableEntity, code: CodeCompileError, err: err}
+	}
+	return &Response{Expr: expr}, nil
+}
+
+// fields 导出表达式或JSON条件树中的变量
+func (s *Server) fields(ctx context.Context, req *Request) (*Response, error) {
+	var fields []string
+	var err error
+	switch {
+	case req.Tree != nil:
+		fields, err = goparser.ExportFields(req.Tree)
+	case req.Expr != "":
+		fields, err = goparser.ExportVars(req.Expr)
+	default:
+		return nil, invalidRequest("missing expr or tree")
+	}
+	if err != nil {
+		return nil, &apiError{status: http.StatusUnprocessableEntity, code: CodeCompileError, err: err}
+	}
+	if fields == nil {
+		fields = []string{}
+	}
+	return &Response{Fields: fields}, nil
+}
+
+// compile 编译表达式，编译结果按表达式缓存
+func (s *Server) compile(expr string) (*goparser.Program, error) {
+	if expr == "" {
+		return nil, invalidRequest("missing expr")
+	}
+	p, err := s.cache.Compile(expr, s.compileOpts...)
+	if err != nil {
+		return nil, &apiError{status: http.StatusUnprocessableEntity, code: CodeCompileError, err: err}
+	}
+	return p, nil
+}
+
+// invalidRequest 请求参数错误
+func invalidRequest(msg string) error {
+	return &apiError{status: http.StatusBadRequest, code: CodeInvalidRequest, err: errors.New(msg)}
+}
+
+// evalError 计算错误，超时返回504
+func evalError(err error) error {
+	status := http.StatusUnprocessableEntity
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	return &apiError{status: status, code: CodeEvalError, err: err}
+}
+
+// writeError 输出错误响应
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, &Response{Error: &Error{Code: code, Message: msg}})
+}
+
+// writeJSON 输出JSON响应，响应无法序列化（如计算结果为NaN、±Inf）时返回计算错误
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	// 表达式中常见 &&、<、>，不转义HTML字符
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		buf.Reset()
+		status = http.StatusUnprocessableEntity
+		_ = enc.Encode(&Response{Error: &Error{Code: CodeEvalError, Message: fmt.Sprintf("encode result: %v", err)}})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(buf.Bytes())
+}
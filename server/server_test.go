@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	goparser "github.com/BeCrafter/go-parser"
+)
+
+func TestServer(t *testing.T) {
+	srv := httptest.NewServer(New(MaxBodyBytes(512), CompileOptions(goparser.DenyVars("password"))))
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{name: "eval", path: "/eval", body: `{"expr": "price * 2 + 1", "data": {"price": 100}}`, status: 200, want: `{"result":201}`},
+		{name: "eval_float", path: "/eval", body: `{"expr": "2 * rate", "data": {"rate": 0.25}}`, status: 200, want: `{"result":0.5}`},
+		{name: "eval_inf", path: "/eval", body: `{"expr": "pow(10, 400)"}`, status: 422, want: `{"error":{"code":"eval_error","message":"encode result: json: unsupported value: +Inf"}}`},
+		{name: "eval_nan", path: "/eval", body: `{"expr": "pow(-1, 0.5)"}`, status: 422, want: `{"error":{"code":"eval_error","message":"encode result: json: unsupported value: NaN"}}`},
+		{name: "eval_false", path: "/eval", body: `{"expr": "a > 1", "data": {"a": 0}}`, status: 200, want: `{"result":false}`},
+		{name: "match", path: "/match", body: `{"expr": "age >= 18 && in_array(city, []string{\"bj\"})", "data": {"age": 20, "city": "bj"}}`, status: 200, want: `{"matched":true}`},
+		{name: "match_false", path: "/match", body: `{"expr": "age >= 18", "data": {"age": 2}}`, status: 200, want: `{"matched":false}`},
+		{name: "match_eval_error", path: "/match", body: `{"expr": "age >= 18", "data": {}}`, status: 422, want: `{"error":{"code":"eval_error","message":"<nil>, 18 is nil"}}`},
+		{name: "validate", path: "/validate", body: `{"expr": "a > 1"}`, status: 200, want: `{"valid":true}`},
+		{name: "validate_issues", path: "/validate", body: `{"expr": "a[0] == 1 && x.(int) > 2"}`, status: 200, want: `{"valid":false,"issues":["1:1: index expression","1:14: type assertion"]}`},
+		{name: "validate_policy", path: "/validate", body: `{"expr": "password == \"x\""}`, status: 200, want: `{"valid":false,"issues":["policy violation: var: var password is not allowed"]}`},
+		{name: "expression", path: "/expression", body: `{"tree": {"connector": "AND", "children": [{"op": "GE", "field": "age", "value": 18}, {"op": "EQ", "field": "name", "value": "tom"}]}}`, status: 200, want: `{"expr":"(age >= 18 && name == \"tom\")"}`},
+		{name: "fields_expr", path: "/fields", body: `{"expr": "max(a, b) > c"}`, status: 200, want: `{"fields":["a","b","c"]}`},
+		{name: "fields_tree", path: "/fields", body: `{"tree": {"connector": "AND", "children": [{"op": "GE", "field": "age", "value": 18}]}}`, status: 200, want: `{"fields":["age"]}`},
+		{name: "compile_error", path: "/eval", body: `{"expr": "a +"}`, status: 422, want: `{"error":{"code":"compile_error","message":"1:4: expected operand, found 'EOF'"}}`},
+		{name: "missing_expr", path: "/match", body: `{}`, status: 400, want: `{"error":{"code":"invalid_request","message":"missing expr"}}`},
+		{name: "bad_json", path: "/eval", body: `{"expr": `, status: 400},
+		{name: "unknown_field", path: "/eval", body: `{"rule": "a"}`, status: 400},
+		{name: "too_large", path: "/eval", body: `{"expr": "` + strings.Repeat("a", 600) + `"}`, status: 413, want: `{"error":{"code":"payload_too_large","message":"request body exceeds 512 bytes"}}`},
+		{name: "method", method: http.MethodGet, path: "/eval", status: 405},
+		{name: "not_found", path: "/rules", body: `{}`, status: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req, _ := http.NewRequest(method, srv.URL+tt.path, strings.NewReader(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			var body json.RawMessage
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode response failed, err=%v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("%s %s status=%d, want %d, body=%s", method, tt.path, resp.StatusCode, tt.status, body)
+			}
+			if tt.want != "" && string(body) != tt.want {
+				t.Errorf("%s %s body=%s, want %s", method, tt.path, body, tt.want)
+			}
+			if tt.status != 200 && !strings.Contains(string(body), `"error":{"code":`) {
+				t.Errorf("%s %s error body=%s", method, tt.path, body)
+			}
+		})
+	}
+}
+
+func TestServerCache(t *testing.T) {
+	s := New(CacheSize(2))
+	post := func(expr string) {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/match", strings.NewReader(fmt.Sprintf(`{"expr": %q, "data": {"a": 1}}`, expr))))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("match %s status=%d, body=%s", expr, rec.Code, rec.Body.String())
+		}
+	}
+
+	post("a == 1")
+	p, _ := s.cache.Get("a == 1")
+	post("a == 1")
+	if got, _ := s.cache.Get("a == 1"); got != p || s.cache.Stats().Len != 1 {
+		t.Errorf("cache did not reuse compiled program")
+	}
+	post("a == 2")
+	post("a == 1")
+	post("a == 3") // 淘汰最久未使用的 a == 2
+	if _, ok := s.cache.Get("a == 2"); ok || s.cache.Stats().Len != 2 {
+		t.Errorf("cache did not evict least recently used program")
+	}
+	if _, ok := s.cache.Get("a == 1"); !ok {
+		t.Errorf("cache evicted recently used program")
+	}
+
+	s = New(CacheSize(0))
+	post("a == 1")
+	if s.cache.Stats().Len != 0 {
+		t.Errorf("cache size 0 should disable caching")
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	goparser.RegisterFuncContext("test_server_sleep", func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+		}
+		return true
+	})
+
+	s := New(Timeout(20 * time.Millisecond))
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/match", strings.NewReader(`{"expr": "test_server_sleep()", "data": {}}`)))
+
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusGatewayTimeout || resp.Error == nil || !reflect.DeepEqual(resp.Error.Code, CodeEvalError) {
+		t.Errorf("timeout status=%d, body=%s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,245 @@
+package goparser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"math"
+	"strings"
+	"time"
+)
+
+// RegisterStdlib 注册Go标准库兼容函数包，提供 strings、math、time 中
+// 无副作用的常用函数及常量，如 strings.HasPrefix(name, "a")、time.Since(t) > 24 * time.Hour
+func RegisterStdlib() {
+	// strings
+	RegisterFuncContext("strings.Contains", stringsPredicate("strings.Contains", strings.Contains))
+	RegisterFuncContext("strings.ContainsAny", stringsPredicate("strings.ContainsAny", strings.ContainsAny))
+	RegisterFuncContext("strings.HasPrefix", stringsPredicate("strings.HasPrefix", strings.HasPrefix))
+	RegisterFuncContext("strings.HasSuffix", stringsPredicate("strings.HasSuffix", strings.HasSuffix))
+	RegisterFuncContext("strings.EqualFold", stringsPredicate("strings.EqualFold", strings.EqualFold))
+	RegisterFuncContext("strings.Index", stringsIndex("strings.Index", strings.Index))
+	RegisterFuncContext("strings.LastIndex", stringsIndex("strings.LastIndex", strings.LastIndex))
+	RegisterFuncContext("strings.Count", stringsIndex("strings.Count", strings.Count))
+	RegisterFuncContext("strings.ToLower", stringsUnary("strings.ToLower", strings.ToLower))
+	RegisterFuncContext("strings.ToUpper", stringsUnary("strings.ToUpper", strings.ToUpper))
+	RegisterFuncContext("strings.TrimSpace", stringsUnary("strings.TrimSpace", strings.TrimSpace))
+	RegisterFuncContext("strings.Trim", stringsBinary("strings.Trim", strings.Trim))
+	RegisterFuncContext("strings.TrimLeft", stringsBinary("strings.TrimLeft", strings.TrimLeft))
+	RegisterFuncContext("strings.TrimRight", stringsBinary("strings.TrimRight", strings.TrimRight))
+	RegisterFuncContext("strings.TrimPrefix", stringsBinary("strings.TrimPrefix", strings.TrimPrefix))
+	RegisterFuncContext("strings.TrimSuffix", stringsBinary("strings.TrimSuffix", strings.TrimSuffix))
+	RegisterFuncContext("strings.ReplaceAll", stringsReplaceAll)
+
+	// math
+	RegisterFuncContext("math.Abs", mathUnary("math.Abs", math.Abs))
+	RegisterFuncContext("math.Ceil", mathUnary("math.Ceil", math.Ceil))
+	RegisterFuncContext("math.Floor", mathUnary("math.Floor", math.Floor))
+	RegisterFuncContext("math.Round", mathUnary("math.Round", math.Round))
+	RegisterFuncContext("math.Trunc", mathUnary("math.Trunc", math.Trunc))
+	RegisterFuncContext("math.Sqrt", mathUnary("math.Sqrt", math.Sqrt))
+	RegisterFuncContext("math.Cbrt", mathUnary("math.Cbrt", math.Cbrt))
+	RegisterFuncContext("math.Exp", mathUnary("math.Exp", math.Exp))
+	RegisterFuncContext("math.Log", mathUnary("math.Log", math.Log))
+	RegisterFuncContext("math.Log2", mathUnary("math.Log2", math.Log2))
+	RegisterFuncContext("math.Log10", mathUnary("math.Log10", math.Log10))
+	RegisterFuncContext("math.Pow", mathBinary("math.Pow", math.Pow))
+	RegisterFuncContext("math.Max", mathBinary("math.Max", math.Max))
+	RegisterFuncContext("math.Min", mathBinary("math.Min", math.Min))
+	RegisterFuncContext("math.Mod", mathBinary("math.Mod", math.Mod))
+	RegisterFuncContext("math.Hypot", mathBinary("math.Hypot", math.Hypot))
+	constNameMap["math.Pi"] = math.Pi
+	constNameMap["math.E"] = math.E
+	constNameMap["math.MaxInt64"] = int64(math.MaxInt64)
+	constNameMap["math.MinInt64"] = int64(math.MinInt64)
+
+	// time（时长统一以int64纳秒表示，与 time.Duration 一致）
+	RegisterFuncContext("time.Now", timeNow)
+	RegisterFuncContext("time.Since", timeSince)
+	RegisterFuncContext("time.Until", timeUntil)
+	RegisterFuncContext("time.Parse", timeParse)
+	RegisterFuncContext("time.ParseDuration", timeParseDuration)
+	constNameMap["time.Nanosecond"] = int64(time.Nanosecond)
+	constNameMap["time.Microsecond"] = int64(time.Microsecond)
+	constNameMap["time.Millisecond"] = int64(time.Millisecond)
+	constNameMap["time.Second"] = int64(time.Second)
+	constNameMap["time.Minute"] = int64(time.Minute)
+	constNameMap["time.Hour"] = int64(time.Hour)
+	constNameMap["time.RFC3339"] = time.RFC3339
+	constNameMap["time.DateTime"] = "2006-01-02 15:04:05"
+	constNameMap["time.DateOnly"] = "2006-01-02"
+}
+
+// evalStrings 计算参数并转为字符串
+func evalStrings(ctx context.Context, name string, args []ast.Expr, data map[string]interface{}, n int) ([]string, error) {
+	values, err := evalArgs(ctx, name, args, data, n)
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, 0, n)
+	for _, v := range values {
+		s, err := castToString(v)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// stringsPredicate 适配 func(s, t string) bool 形式的函数
+func stringsPredicate(name string, f func(s, t string) bool) FuncContext {
+	return func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		strs, err := evalStrings(ctx, name, args, data, 2)
+		if err != nil {
+			return err
+		}
+		return f(strs[0], strs[1])
+	}
+}
+
+// stringsIndex 适配 func(s, t string) int 形式的函数
+func stringsIndex(name string, f func(s, t string) int) FuncContext {
+	return func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		strs, err := evalStrings(ctx, name, args, data, 2)
+		if err != nil {
+			return err
+		}
+		return int64(f(strs[0], strs[1]))
+	}
+}
+
+// stringsUnary 适配 func(s string) string 形式的函数
+func stringsUnary(name string, f func(s string) string) FuncContext {
+	return func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		strs, err := evalStrings(ctx, name, args, data, 1)
+		if err != nil {
+			return err
+		}
+		return f(strs[0])
+	}
+}
+
+// stringsBinary 适配 func(s, t string) string 形式的函数
+func stringsBinary(name string, f func(s, t string) string) FuncContext {
+	return func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		strs, err := evalStrings(ctx, name, args, data, 2)
+		if err != nil {
+			return err
+		}
+		return f(strs[0], strs[1])
+	}
+}
+
+// stringsReplaceAll strings.ReplaceAll(s, old, new)
+func stringsReplaceAll(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	strs, err := evalStrings(ctx, "strings.ReplaceAll", args, data, 3)
+	if err != nil {
+		return err
+	}
+	return strings.ReplaceAll(strs[0], strs[1], strs[2])
+}
+
+// mathUnary 适配 func(x float64) float64 形式的函数
+func mathUnary(name string, f func(x float64) float64) FuncContext {
+	return func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		nums, err := evalNumberArgs(ctx, name, args, data, 1, 1)
+		if err != nil {
+			return err
+		}
+		return f(nums[0].float())
+	}
+}
+
+// mathBinary 适配 func(x, y float64) float64 形式的函数
+func mathBinary(name string, f func(x, y float64) float64) FuncContext {
+	return func(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+		nums, err := evalNumberArgs(ctx, name, args, data, 2, 2)
+		if err != nil {
+			return err
+		}
+		return f(nums[0].float(), nums[1].float())
+	}
+}
+
+// toTime 转换为时间类型，支持 time.Time、RFC3339 字符串及 Unix 秒级时间戳
+func toTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		if t != nil {
+			return *t, nil
+		}
+	case string:
+		return time.Parse(time.RFC3339, t)
+	case int, int32, int64, json.Number:
+		sec, err := castToInt64(t)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(sec.(int64), 0), nil
+	}
+	return time.Time{}, fmt.Errorf("type cast failure, unexpected time value: %v", v)
+}
+
+// timeNow time.Now()
+func timeNow(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	if _, err := evalArgs(ctx, "time.Now", args, data, 0); err != nil {
+		return err
+	}
+	return time.Now()
+}
+
+// timeSince time.Since(t)，返回int64纳秒
+func timeSince(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	values, err := evalArgs(ctx, "time.Since", args, data, 1)
+	if err != nil {
+		return err
+	}
+	t, err := toTime(values[0])
+	if err != nil {
+		return err
+	}
+	return int64(time.Since(t))
+}
+
+// timeUntil time.Until(t)，返回int64纳秒
+func timeUntil(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	values, err := evalArgs(ctx, "time.Until", args, data, 1)
+	if err != nil {
+		return err
+	}
+	t, err := toTime(values[0])
+	if err != nil {
+		return err
+	}
+	return int64(time.Until(t))
+}
+
+// timeParse time.Parse(layout, value)
+func timeParse(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	strs, err := evalStrings(ctx, "time.Parse", args, data, 2)
+	if err != nil {
+		return err
+	}
+	t, err := time.Parse(strs[0], strs[1])
+	if err != nil {
+		return err
+	}
+	return t
+}
+
+// timeParseDuration time.ParseDuration(s)，返回int64纳秒
+func timeParseDuration(ctx context.Context, args []ast.Expr, data map[string]interface{}) interface{} {
+	strs, err := evalStrings(ctx, "time.ParseDuration", args, data, 1)
+	if err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(strs[0])
+	if err != nil {
+		return err
+	}
+	return int64(d)
+}
@@ -0,0 +1,269 @@
+package goparser
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// RuleVersion 规则存储当前生效的规则版本信息
+type RuleVersion struct {
+	Version  int64     `json:"version"`   // 版本号，每次成功加载加一，从1开始
+	Checksum string    `json:"checksum"`  // 规则文件内容的sha256
+	LoadedAt time.Time `json:"loaded_at"` // 加载时间
+	Files    []string  `json:"files"`     // 加载的规则文件
+	Rules    int       `json:"rules"`     // 规则数量
+}
+
+// StoreOption 规则存储选项函数
+type StoreOption func(o *storeOptions)
+
+// storeOptions 规则存储选项
+type storeOptions struct {
+	compile  []CompileOption
+	onReload func(v RuleVersion, err error)
+}
+
+// StoreCompileOptions 编译规则时使用的编译选项
+func StoreCompileOptions(opts ...CompileOption) StoreOption {
+	return func(o *storeOptions) { o.compile = append(o.compile, opts...) }
+}
+
+// OnReload Watch 检测到规则文件变化并重新加载后的回调，加载失败时 err 不为nil，v 为继续生效的版本。
+// 文件未再次变化时不会重复回调，即使其依然无法加载
+func OnReload(fn func(v RuleVersion, err error)) StoreOption {
+	return func(o *storeOptions) { o.onReload = fn }
+}
+
+// RuleStore 从目录或JSON/YAML文件加载的规则集，规则文件变化时重新编译并原子替换，加载失败时保留上一版本。
+// 规则文件为规则名到规则的映射，规则为 Expression 使用的JSON条件树，可附加 priority 字段：
+//
+//	{"adult": {"priority": 1, "connector": "AND", "children": [{"op": "GE", "field": "age", "value": 18}]}}
+//
+// 目录中的 .json、.yaml、.yml 文件均会被加载，规则名在全部文件中不能重复
+type RuleStore struct {
+	path string
+	opts storeOptions
+
+	mu       sync.Mutex   // 保证同时只有一次加载
+	current  atomic.Value // *ruleSnapshot
+	checksum string       // 最近一次编译（无论成败）的文件内容摘要，用于判断文件是否变化
+	buildErr error        // 最近一次编译的错误
+	lastErr  error        // 最近一次加载的错误，包括读取文件的错误
+}
+
+// ruleSnapshot 一个版本的规则集
+type ruleSnapshot struct {
+	rules   *RuleSet
+	version RuleVersion
+}
+
+// NewRuleStore 从目录或文件加载规则，首次加载失败时返回error
+func NewRuleStore(path string, opts ...StoreOption) (*RuleStore, error) {
+	s := &RuleStore{path: path}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RuleSet 返回当前生效的规则集，返回的规则集不会被后续加载修改
+func (s *RuleStore) RuleSet() *RuleSet {
+	return s.snapshot().rules
+}
+
+// Version 返回当前生效的规则版本信息
+func (s *RuleStore) Version() RuleVersion {
+	return s.snapshot().version
+}
+
+// LastError 返回最近一次加载的错误，加载成功后为nil
+func (s *RuleStore) LastError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// snapshot 当前生效的版本
+func (s *RuleStore) snapshot() *ruleSnapshot {
+	return s.current.Load().(*ruleSnapshot)
+}
+
+// Reload 检查规则文件，内容变化时重新加载，返回是否加载了新版本。
+// 加载失败时保留当前版本并返回error，文件未再次变化时不会重复加载
+func (s *RuleStore) Reload() (bool, error) {
+	changed, _, err := s.reload()
+	return changed, err
+}
+
+// reload 检查并加载规则文件，notify 表示检测到文件变化（包括首次出现的读取错误），需要通知 OnReload
+func (s *RuleStore) reload() (changed, notify bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, contents, err := readRuleFiles(s.path)
+	if err != nil {
+		notify = s.lastErr == nil || s.lastErr.Error() != err.Error()
+		s.lastErr = err
+		return false, notify, err
+	}
+	checksum := rulesChecksum(files, contents)
+	if checksum == s.checksum {
+		// 文件恢复可读时，读取错误之前的编译结果依然有效，无需通知
+		s.lastErr = s.buildErr
+		return false, false, s.lastErr
+	}
+	s.checksum = checksum
+
+	rs, err := buildRuleSet(files, contents, s.opts.compile)
+	s.buildErr, s.lastErr = err, err
+	if err != nil {
+		return false, true, err
+	}
+
+	var version int64 = 1
+	if prev, ok := s.current.Load().(*ruleSnapshot); ok {
+		version = prev.version.Version + 1
+	}
+	s.current.Store(&ruleSnapshot{rules: rs, version: RuleVersion{
+		Version:  version,
+		Checksum: checksum,
+		LoadedAt: time.Now(),
+		Files:    files,
+		Rules:    rs.Len(),
+	}})
+	return true, true, nil
+}
+
+// Watch 按照指定间隔轮询规则文件，变化时重新加载，阻塞至上下文结束并返回上下文的错误。
+// 轮询不依赖文件系统通知，适用于网络文件系统、容器挂载的配置等场景
+func (s *RuleStore) Watch(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			_, notify, err := s.reload()
+			if notify && s.opts.onReload != nil {
+				s.opts.onReload(s.Version(), err)
+			}
+		}
+	}
+}
+
+// ruleFileExts 支持的规则文件扩展名
+var ruleFileExts = map[string]bool{".json": true, ".yaml": true, ".yml": true}
+
+// readRuleFiles 读取规则文件，路径为目录时读取其中全部规则文件，按文件名排序
+func readRuleFiles(path string) ([]string, [][]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			if !entry.IsDir() && ruleFileExts[strings.ToLower(filepath.Ext(entry.Name()))] {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+
+	contents := make([][]byte, 0, len(files))
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		contents = append(contents, b)
+	}
+	return files, contents, nil
+}
+
+// rulesChecksum 计算规则文件名及内容的摘要
+func rulesChecksum(files []string, contents [][]byte) string {
+	h := sha256.New()
+	for i, file := range files {
+		fmt.Fprintf(h, "%s\x00%d\x00", file, len(contents[i]))
+		h.Write(contents[i])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// buildRuleSet 解析并编译规则文件，任一规则出错时返回error
+func buildRuleSet(files []string, contents [][]byte, opts []CompileOption) (*RuleSet, error) {
+	defs := make(map[string]map[string]interface{})
+	for i, file := range files {
+		rules, err := parseRuleFile(file, contents[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse %s", file)
+		}
+		for name, def := range rules {
+			if _, dup := defs[name]; dup {
+				return nil, fmt.Errorf("%s: duplicate rule %s", file, name)
+			}
+			defs[name] = def
+		}
+	}
+
+	// 按规则名排序添加，保证同优先级规则的顺序稳定
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rs := NewRuleSet()
+	for _, name := range names {
+		def := defs[name]
+		v, err := castToInt64(def["priority"])
+		priority, ok := v.(int64)
+		if err != nil || !ok {
+			return nil, fmt.Errorf("rule %s: invalid priority %v", name, def["priority"])
+		}
+		expr, err := Expression(def)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule %s", name)
+		}
+		if err := rs.Add(name, int(priority), expr, opts...); err != nil {
+			return nil, errors.Wrapf(err, "rule %s", name)
+		}
+	}
+	return rs, nil
+}
+
+// parseRuleFile 按扩展名解析JSON或YAML规则文件
+func parseRuleFile(file string, content []byte) (map[string]map[string]interface{}, error) {
+	var rules map[string]map[string]interface{}
+	var err error
+	if ext := strings.ToLower(filepath.Ext(file)); ext == ".yaml" || ext == ".yml" {
+		err = yaml.Unmarshal(content, &rules)
+	} else {
+		err = Jsoniter.Unmarshal(content, &rules)
+	}
+	return rules, err
+}
@@ -64,7 +64,7 @@ func castToString(data interface{}) (string, error) {
 
 func castToInt64(data interface{}) (interface{}, error) {
 	if data == nil {
-		return 0, nil
+		return int64(0), nil
 	}
 
 	switch t := data.(type) {
@@ -87,7 +87,7 @@ func castToInt64(data interface{}) (interface{}, error) {
 
 func castToFloat(data interface{}) (interface{}, error) {
 	if data == nil {
-		return 0, nil
+		return float64(0), nil
 	}
 
 	switch t := data.(type) {
@@ -122,6 +122,43 @@ func castToFloat(data interface{}) (interface{}, error) {
 	return strconv.ParseFloat(fmt.Sprint(data), 64)
 }
 
+// castToFloat64 转换为float64类型（castToFloat 对部分整型返回float32）
+func castToFloat64(data interface{}) (float64, error) {
+	v, err := castToFloat(data)
+	if err != nil {
+		return 0, err
+	}
+	switch t := v.(type) {
+	case float32:
+		return float64(t), nil
+	case float64:
+		return t, nil
+	}
+	return 0, fmt.Errorf("type cast failure, unexpected float value: %v", data)
+}
+
+// NormalizeJSON 将以 json.Number 解析（见 json.Decoder.UseNumber）的JSON数据中的整数转换为int64、小数转换为float64，
+// 与Go代码中传入的数据类型一致。切片及map原地转换，返回转换后的值
+func NormalizeJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = NormalizeJSON(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = NormalizeJSON(v[k])
+		}
+	}
+	return v
+}
+
 // StringBuilder 高效字符串拼接
 func StringBuilder(p ...interface{}) string {
 	var b strings.Builder
@@ -166,7 +203,25 @@ func StringBuilder(p ...interface{}) string {
 	return b.String()
 }
 
-// InArray 判断数组中是否存在某元素
+// PanicError 表达式计算过程中发生的panic
+type PanicError struct {
+	Expr  interface{} // 发生panic的表达式
+	Value interface{} // recover得到的值
+}
+
+// Error 实现error接口
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic while evaluating %v: %v", e.Expr, e.Value)
+}
+
+// recoverPanic 将panic转换为错误，需通过defer调用
+func recoverPanic(err *error, expr interface{}) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Expr: expr, Value: r}
+	}
+}
+
+// InArray 判断数组中是否存在某元素，haystack 不是数组、切片或map时返回false
 func InArray(needle interface{}, haystack interface{}) bool {
 	val := reflect.ValueOf(haystack)
 	switch val.Kind() {
@@ -182,8 +237,6 @@ func InArray(needle interface{}, haystack interface{}) bool {
 				return true
 			}
 		}
-	default:
-		panic("haystack: haystack type muset be slice, array or map")
 	}
 
 	return false
@@ -0,0 +1,224 @@
+package goparser
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"strings"
+)
+
+// 规则语言支持的二元运算符
+var supportedBinaryOps = map[token.Token]bool{
+	token.LAND: true, token.LOR: true,
+	token.EQL: true, token.NEQ: true, token.GTR: true, token.LSS: true, token.GEQ: true, token.LEQ: true,
+	token.ADD: true, token.SUB: true, token.MUL: true, token.QUO: true, token.REM: true,
+	token.AND: true, token.OR: true, token.XOR: true, token.AND_NOT: true, token.SHL: true, token.SHR: true,
+}
+
+// 规则语言支持的一元运算符
+var supportedUnaryOps = map[token.Token]bool{
+	token.NOT: true, token.SUB: true, token.ADD: true, token.XOR: true,
+}
+
+// SyntaxIssue 表达式中不属于规则语言的语法结构
+type SyntaxIssue struct {
+	Pos  token.Position // 所在位置
+	Desc string         // 语法结构描述
+}
+
+// String 返回 "行:列: 描述" 形式的说明
+func (i SyntaxIssue) String() string {
+	return fmt.Sprintf("%d:%d: %s", i.Pos.Line, i.Pos.Column, i.Desc)
+}
+
+// SyntaxError 表达式中全部不受支持的语法结构
+type SyntaxError struct {
+	Issues []SyntaxIssue
+}
+
+// Error 实现error接口
+func (e *SyntaxError) Error() string {
+	issues := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		issues = append(issues, issue.String())
+	}
+	return "unsupported syntax: " + strings.Join(issues, "; ")
+}
+
+// ErrorIssues 将 Validate、Compile 等返回的错误拆分为逐条的问题说明：*SyntaxError 及解析错误按位置逐条列出，
+// 形如 "行:列: 描述"，其余错误作为一条问题
+func ErrorIssues(err error) []string {
+	var issues []string
+	var syntaxErr *SyntaxError
+	var scanErrs scanner.ErrorList
+	switch {
+	case errors.As(err, &syntaxErr):
+		for _, issue := range syntaxErr.Issues {
+			issues = append(issues, issue.String())
+		}
+	case errors.As(err, &scanErrs):
+		for _, e := range scanErrs {
+			issues = append(issues, fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Msg))
+		}
+	default:
+		issues = append(issues, err.Error())
+	}
+	return issues
+}
+
+// Validate 校验表达式是否只使用规则语言支持的语法，返回的 *SyntaxError 中列出全部问题及其位置
+func Validate(expr string) (err error) {
+	defer recoverPanic(&err, expr)
+
+	root, fset, err := parseExprWithFileSet(expr)
+	if err != nil {
+		return err
+	}
+	return validateExpr(root, fset)
+}
+
+// validateExpr 遍历语法树，收集不受支持的节点类型及运算符
+func validateExpr(root ast.Expr, fset *token.FileSet) error {
+	v := &validator{fset: fset}
+	v.walk(root)
+	if len(v.issues) > 0 {
+		return &SyntaxError{Issues: v.issues}
+	}
+	return nil
+}
+
+// validator 语法校验器
+type validator struct {
+	fset   *token.FileSet
+	issues []SyntaxIssue
+}
+
+// report 记录不受支持的语法结构
+func (v *validator) report(node ast.Node, format string, args ...interface{}) {
+	v.issues = append(v.issues, SyntaxIssue{
+		Pos:  v.fset.Position(node.Pos()),
+		Desc: fmt.Sprintf(format, args...),
+	})
+}
+
+// walk 递归校验节点
+func (v *validator) walk(expr ast.Expr) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+	case *ast.BasicLit:
+		switch expr.Kind {
+		case token.INT, token.FLOAT, token.STRING:
+		default:
+			v.report(expr, "%s literal %s", strings.ToLower(expr.Kind.String()), expr.Value)
+		}
+	case *ast.ParenExpr:
+		v.walk(expr.X)
+	case *ast.UnaryExpr:
+		if !supportedUnaryOps[expr.Op] {
+			v.report(expr, "unary operator %s", expr.Op)
+		}
+		v.walk(expr.X)
+	case *ast.BinaryExpr:
+		if !supportedBinaryOps[expr.Op] {
+			v.report(expr, "binary operator %s", expr.Op)
+		}
+		v.walk(expr.X)
+		v.walk(expr.Y)
+	case *ast.CallExpr:
+		if expr.Ellipsis.IsValid() {
+			v.report(expr, "variadic call with ...")
+		}
+		switch fun := expr.Fun.(type) {
+		case *ast.Ident:
+		case *ast.SelectorExpr:
+			// 命名空间函数无需校验，值方法需校验接收者
+			if name, ok := selectorName(fun); !ok || funcNameMap[name] == nil {
+				v.walk(fun.X)
+			}
+		default:
+			v.report(fun, "call of %s", describeNode(fun))
+		}
+		for _, arg := range expr.Args {
+			v.walk(arg)
+		}
+	case *ast.SelectorExpr:
+		if _, ok := selectorName(expr); !ok {
+			v.report(expr, "selector on %s", describeNode(expr.X))
+		}
+	case *ast.CompositeLit:
+		v.walkCompositeLit(expr, false)
+	default:
+		v.report(expr, "%s", describeNode(expr))
+	}
+}
+
+// walkCompositeLit 校验数组字面量，只支持 []T{...}、[N]T{...} 形式，nested 表示嵌套在数组字面量中可省略类型
+func (v *validator) walkCompositeLit(lit *ast.CompositeLit, nested bool) {
+	arr, isArray := lit.Type.(*ast.ArrayType)
+	switch {
+	case lit.Type == nil && !nested:
+		v.report(lit, "untyped composite literal")
+	case lit.Type != nil && !isArray:
+		v.report(lit, "composite literal of %s", describeNode(lit.Type))
+	}
+
+	for _, elt := range lit.Elts {
+		switch elt := elt.(type) {
+		case *ast.KeyValueExpr:
+			v.report(elt, "keyed element in composite literal")
+		case *ast.CompositeLit:
+			if elt.Type == nil && isArray {
+				if _, ok := arr.Elt.(*ast.ArrayType); ok {
+					v.walkCompositeLit(elt, true)
+					continue
+				}
+			}
+			v.walk(elt)
+		default:
+			v.walk(elt)
+		}
+	}
+}
+
+// describeNode 描述语法树节点类型
+func describeNode(node ast.Node) string {
+	switch node := node.(type) {
+	case *ast.FuncLit:
+		return "func literal"
+	case *ast.TypeAssertExpr:
+		return "type assertion"
+	case *ast.SliceExpr:
+		return "slice expression"
+	case *ast.IndexExpr:
+		return "index expression"
+	case *ast.StarExpr:
+		return "pointer expression"
+	case *ast.KeyValueExpr:
+		return "key-value expression"
+	case *ast.CompositeLit:
+		return "composite literal"
+	case *ast.CallExpr:
+		return "call expression"
+	case *ast.ArrayType:
+		return "array type"
+	case *ast.MapType:
+		return "map type"
+	case *ast.ChanType:
+		return "chan type"
+	case *ast.StructType:
+		return "struct type"
+	case *ast.InterfaceType:
+		return "interface type"
+	case *ast.FuncType:
+		return "func type"
+	case *ast.Ident:
+		return node.Name
+	case *ast.BasicLit:
+		return "literal " + node.Value
+	case *ast.ParenExpr:
+		return "parenthesized expression"
+	}
+	return strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+}
@@ -0,0 +1,458 @@
+package goparser
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"math"
+)
+
+// vkind 虚拟机中值的类型，与数据中值的动态类型一一对应，保证与 Eval 的计算语义一致
+type vkind uint8
+
+// 值类型定义
+const (
+	vNil     vkind = iota // nil
+	vBool                 // bool
+	vInt                  // int
+	vInt64                // int64
+	vFloat64              // float64
+	vString               // string
+	vAny                  // 其他类型（包括error），保存在 ref 中
+)
+
+// value 虚拟机中的值，常用类型不经过 interface{} 装箱
+type value struct {
+	kind vkind
+	n    int64 // bool、int、int64
+	f    float64
+	s    string
+	ref  interface{}
+}
+
+// valueOf 将任意值转换为虚拟机中的值
+func valueOf(v interface{}) value {
+	switch v := v.(type) {
+	case nil:
+		return value{}
+	case bool:
+		return boolValue(v)
+	case int:
+		return value{kind: vInt, n: int64(v)}
+	case int64:
+		return value{kind: vInt64, n: v}
+	case float64:
+		return value{kind: vFloat64, f: v}
+	case string:
+		return value{kind: vString, s: v}
+	}
+	return value{kind: vAny, ref: v}
+}
+
+// boolValue 创建bool值
+func boolValue(b bool) value {
+	if b {
+		return value{kind: vBool, n: 1}
+	}
+	return value{kind: vBool}
+}
+
+// box 转换为 Eval 返回的原始值
+func (v value) box() interface{} {
+	switch v.kind {
+	case vBool:
+		return v.n != 0
+	case vInt:
+		return int(v.n)
+	case vInt64:
+		return v.n
+	case vFloat64:
+		return v.f
+	case vString:
+		return v.s
+	case vAny:
+		return v.ref
+	}
+	return nil
+}
+
+// opcode 指令类型
+type opcode uint8
+
+// 指令定义
+const (
+	opConst  opcode = iota // 压入常量 consts[arg]
+	opLoad                 // 压入变量 names[arg]，数据中不存在时通过变量解析器获取
+	opNode                 // 压入语法树节点 nodes[arg] 的计算结果，用于函数调用、数组字面量等
+	opBinary               // 弹出两个值，压入二元运算结果，nodes[arg] 为对应的语法树节点
+	opUnary                // 弹出一个值，压入一元运算结果，nodes[arg] 为对应的语法树节点
+	opShared               // 规则集计算时 nodes[arg] 为已计算的公共子表达式，压入缓存的结果并跳转到 to
+	opJump                 // 栈顶为 && 左侧的false或 || 左侧的true时结果已确定，跳过右侧及运算指令至 to
+)
+
+// instr 虚拟机指令
+type instr struct {
+	op    opcode
+	tok   token.Token
+	arg   int32
+	to    int32 // 跳转目标
+	steps int32 // 执行指令时计入的步数，与 Eval 访问的语法树节点数一致
+}
+
+// bytecode 语法树编译后的指令序列，编译后只读
+type bytecode struct {
+	code     []instr
+	consts   []value
+	names    []string
+	nodes    []ast.Expr
+	maxStack int
+
+	parens int // 编译时待计入下一条指令的括号节点数
+}
+
+// 计算栈不超过该深度时使用栈上数组，避免内存分配
+const vmStackSize = 16
+
+// compileBytecode 将语法树编译为指令序列。每条指令计入其对应语法树节点的步数：
+// 括号计入其后的第一条指令，opShared 计入二元、一元表达式节点，opNode 的步数由 Eval 计入
+func compileBytecode(root ast.Expr) *bytecode {
+	c := &bytecode{}
+	c.emit(root, 0)
+	return c
+}
+
+// emit 后序遍历生成指令，depth 为执行该节点前的栈深度
+func (c *bytecode) emit(expr ast.Expr, depth int) {
+	if depth+1 > c.maxStack {
+		c.maxStack = depth + 1
+	}
+	switch expr := expr.(type) {
+	case *ast.ParenExpr:
+		c.parens++
+		c.emit(expr.X, depth)
+	case *ast.BasicLit:
+		c.consts = append(c.consts, valueOf(getlitValue(expr)))
+		c.add(opConst, token.ILLEGAL, len(c.consts)-1, 1)
+	case *ast.Ident:
+		switch expr.Name {
+		case "true", "false":
+			c.consts = append(c.consts, boolValue(expr.Name == "true"))
+			c.add(opConst, token.ILLEGAL, len(c.consts)-1, 1)
+		default:
+			c.names = append(c.names, expr.Name)
+			c.add(opLoad, token.ILLEGAL, len(c.names)-1, 1)
+		}
+	case *ast.BinaryExpr:
+		node := c.addNode(expr)
+		shared := c.add(opShared, token.ILLEGAL, node, 1)
+		c.emit(expr.X, depth)
+		jump := -1
+		if expr.Op == token.LAND || expr.Op == token.LOR {
+			jump = c.add(opJump, expr.Op, node, 0)
+		}
+		c.emit(expr.Y, depth+1)
+		c.add(opBinary, expr.Op, node, 0)
+		c.code[shared].to = int32(len(c.code))
+		if jump >= 0 {
+			c.code[jump].to = int32(len(c.code))
+		}
+	case *ast.UnaryExpr:
+		node := c.addNode(expr)
+		shared := c.add(opShared, token.ILLEGAL, node, 1)
+		c.emit(expr.X, depth)
+		c.add(opUnary, expr.Op, node, 0)
+		c.code[shared].to = int32(len(c.code))
+	default:
+		c.add(opNode, token.ILLEGAL, c.addNode(expr), 0)
+	}
+}
+
+// add 追加指令，steps 为指令对应语法树节点的步数，连同待计入的括号一起计入，返回指令位置
+func (c *bytecode) add(op opcode, tok token.Token, arg, steps int) int {
+	c.code = append(c.code, instr{op: op, tok: tok, arg: int32(arg), steps: int32(steps + c.parens)})
+	c.parens = 0
+	return len(c.code) - 1
+}
+
+// addNode 追加语法树节点，返回节点位置
+func (c *bytecode) addNode(expr ast.Expr) int {
+	c.nodes = append(c.nodes, expr)
+	return len(c.nodes) - 1
+}
+
+// run 执行指令序列，计算语义与 Eval 一致：常用类型的运算走类型化的快速路径，其余情况交由 Eval 的实现处理。
+// 每条指令按 steps 计入步数，上下文结束或超出步数限制时返回对应的error
+func (c *bytecode) run(ctx context.Context, data map[string]interface{}) value {
+	state := stateFrom(ctx)
+	check := ctx.Done() != nil || (state != nil && state.maxSteps > 0)
+	shared := state != nil && state.shared != nil
+
+	var buf [vmStackSize]value
+	stack := buf[:0]
+	if c.maxStack > vmStackSize {
+		stack = make([]value, 0, c.maxStack)
+	}
+
+	for pc := 0; pc < len(c.code); pc++ {
+		in := c.code[pc]
+		if check && in.steps > 0 {
+			if err := visitNodes(ctx, state, int64(in.steps)); err != nil {
+				return value{kind: vAny, ref: err}
+			}
+		}
+		switch in.op {
+		case opShared:
+			// 公共子表达式已被其他规则计算过时跳过整个子树
+			if shared {
+				if v, hit := state.cache[state.shared[c.nodes[in.arg]]]; hit {
+					stack = append(stack, valueOf(v))
+					pc = int(in.to) - 1
+				}
+			}
+		case opJump:
+			x := stack[len(stack)-1]
+			if x.kind == vBool && (x.n == 0) == (in.tok == token.LAND) {
+				if shared {
+					c.share(state, in.arg, x)
+				}
+				pc = int(in.to) - 1
+			}
+		case opConst:
+			stack = append(stack, c.consts[in.arg])
+		case opLoad:
+			stack = append(stack, valueOf(state.lookupVar(data, c.names[in.arg])))
+		case opNode:
+			v := EvalContext(ctx, c.nodes[in.arg], data)
+			if isAbort(v) {
+				return value{kind: vAny, ref: v}
+			}
+			stack = append(stack, valueOf(v))
+		case opBinary:
+			n := len(stack)
+			x, y := &stack[n-2], &stack[n-1]
+			if !fastBinary(x, y, in.tok) {
+				*x = valueOf(evalBinary(x.box(), y.box(), in.tok))
+			}
+			stack = stack[:n-1]
+			if shared {
+				c.share(state, in.arg, *x)
+			}
+		case opUnary:
+			x := &stack[len(stack)-1]
+			if !fastUnary(x, in.tok) {
+				*x = valueOf(evalUnary(c.nodes[in.arg].(*ast.UnaryExpr), x.box()))
+			}
+			if shared {
+				c.share(state, in.arg, *x)
+			}
+		}
+	}
+	return stack[0]
+}
+
+// share 节点为公共子表达式时缓存计算结果，供规则集中的其他规则复用
+func (c *bytecode) share(state *evalState, node int32, v value) {
+	if key, ok := state.shared[c.nodes[node]]; ok {
+		state.cache[key] = v.box()
+	}
+}
+
+// fastBinary 常用类型的二元运算，结果写入 x，与 evalBinary 一致；出错或不支持时返回false，由 evalBinary 计算
+func fastBinary(x, y *value, op token.Token) bool {
+	switch y.kind {
+	case vInt, vInt64:
+		switch x.kind {
+		case vInt, vInt64:
+			return fastInt64(x, x.n, y.n, op)
+		case vFloat64:
+			return fastFloat64(x, x.f, float64(y.n), op)
+		}
+	case vFloat64:
+		switch x.kind {
+		case vInt, vInt64:
+			return fastFloat64(x, float64(x.n), y.f, op)
+		case vFloat64:
+			return fastFloat64(x, x.f, y.f, op)
+		}
+	case vString:
+		if x.kind == vString {
+			switch op {
+			case token.EQL:
+				*x = boolValue(x.s == y.s)
+				return true
+			case token.NEQ:
+				*x = boolValue(x.s != y.s)
+				return true
+			}
+		}
+	case vBool:
+		if x.kind == vBool {
+			xb, yb := x.n != 0, y.n != 0
+			switch op {
+			case token.LAND:
+				*x = boolValue(xb && yb)
+				return true
+			case token.LOR:
+				*x = boolValue(xb || yb)
+				return true
+			case token.EQL:
+				*x = boolValue(xb == yb)
+				return true
+			case token.NEQ:
+				*x = boolValue(xb != yb)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fastInt64 int64运算，结果写入 v，溢出、除零等情况返回false
+func fastInt64(v *value, x, y int64, op token.Token) bool {
+	switch op {
+	case token.EQL:
+		*v = boolValue(x == y)
+		return true
+	case token.NEQ:
+		*v = boolValue(x != y)
+		return true
+	case token.GTR:
+		*v = boolValue(x > y)
+		return true
+	case token.LSS:
+		*v = boolValue(x < y)
+		return true
+	case token.GEQ:
+		*v = boolValue(x >= y)
+		return true
+	case token.LEQ:
+		*v = boolValue(x <= y)
+		return true
+	case token.ADD:
+		if r := x + y; (r > x) == (y > 0) {
+			*v = value{kind: vInt64, n: r}
+			return true
+		}
+	case token.SUB:
+		if r := x - y; (r < x) == (y > 0) {
+			*v = value{kind: vInt64, n: r}
+			return true
+		}
+	case token.MUL:
+		if x == 0 || y == 0 {
+			*v = value{kind: vInt64}
+			return true
+		}
+		if r := x * y; r/y == x && x != math.MinInt64 && y != math.MinInt64 {
+			*v = value{kind: vInt64, n: r}
+			return true
+		}
+	case token.QUO:
+		if y != 0 && y != -1 {
+			*v = value{kind: vInt64, n: x / y}
+			return true
+		}
+	case token.REM:
+		if y != 0 {
+			*v = value{kind: vInt64, n: x % y}
+			return true
+		}
+	case token.AND:
+		*v = value{kind: vInt64, n: x & y}
+		return true
+	case token.OR:
+		*v = value{kind: vInt64, n: x | y}
+		return true
+	case token.XOR:
+		*v = value{kind: vInt64, n: x ^ y}
+		return true
+	case token.AND_NOT:
+		*v = value{kind: vInt64, n: x &^ y}
+		return true
+	case token.SHL:
+		if y >= 0 {
+			*v = value{kind: vInt64, n: x << uint64(y)}
+			return true
+		}
+	case token.SHR:
+		if y >= 0 {
+			*v = value{kind: vInt64, n: x >> uint64(y)}
+			return true
+		}
+	}
+	return false
+}
+
+// fastFloat64 float64运算，结果写入 v，除零等情况返回false
+func fastFloat64(v *value, x, y float64, op token.Token) bool {
+	switch op {
+	case token.EQL:
+		*v = boolValue(x == y)
+		return true
+	case token.NEQ:
+		*v = boolValue(x != y)
+		return true
+	case token.GTR:
+		*v = boolValue(x > y)
+		return true
+	case token.LSS:
+		*v = boolValue(x < y)
+		return true
+	case token.GEQ:
+		*v = boolValue(x >= y)
+		return true
+	case token.LEQ:
+		*v = boolValue(x <= y)
+		return true
+	case token.ADD:
+		*v = value{kind: vFloat64, f: x + y}
+		return true
+	case token.SUB:
+		*v = value{kind: vFloat64, f: x - y}
+		return true
+	case token.MUL:
+		*v = value{kind: vFloat64, f: x * y}
+		return true
+	case token.QUO:
+		if y != 0 {
+			*v = value{kind: vFloat64, f: x / y}
+			return true
+		}
+	}
+	return false
+}
+
+// fastUnary 常用类型的一元运算，结果写入 x，与 evalUnary 一致；出错或不支持时返回false
+func fastUnary(x *value, op token.Token) bool {
+	switch x.kind {
+	case vBool:
+		if op == token.NOT {
+			*x = boolValue(x.n == 0)
+			return true
+		}
+	case vInt, vInt64:
+		switch op {
+		case token.SUB:
+			if x.n != math.MinInt64 {
+				*x = value{kind: vInt64, n: -x.n}
+				return true
+			}
+		case token.ADD:
+			*x = value{kind: vInt64, n: x.n}
+			return true
+		case token.XOR:
+			*x = value{kind: vInt64, n: ^x.n}
+			return true
+		}
+	case vFloat64:
+		switch op {
+		case token.SUB:
+			*x = value{kind: vFloat64, f: -x.f}
+			return true
+		case token.ADD:
+			*x = value{kind: vFloat64, f: x.f}
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package goparser
+
+import (
+	"context"
+	"encoding/json"
+	"go/ast"
+	"go/token"
+)
+
+// FailedClause 导致规则不匹配的叶子条件
+type FailedClause struct {
+	ChildrenItem             // 条件的变量（或左侧表达式）、运算符标识及期望值
+	Expr         string      `json:"expr"`              // 条件表达式
+	Actual       interface{} `json:"actual"`            // 变量（或左侧表达式）的实际值
+	Negated      bool        `json:"negated,omitempty"` // 条件位于 ! 之内，期望其不成立
+	Err          error       `json:"-"`                 // 条件计算出错
+}
+
+// WhyNot 返回规则不匹配的原因：&& 链中结果为false的叶子条件，|| 中全部不成立的分支条件。
+// 规则匹配时返回nil
+func WhyNot(expr string, data map[string]interface{}) ([]FailedClause, error) {
+	// && 与 || 被短路的右侧依然计算，以便列出全部不成立的条件
+	node, err := explainExpr(context.Background(), expr, data, true)
+	if err != nil {
+		return nil, err
+	}
+	if node.Err == nil {
+		matched, err := resultToBool(expr, node.Value)
+		if err != nil || matched {
+			return nil, err
+		}
+	}
+
+	var clauses []FailedClause
+	collectFailed(node, true, &clauses)
+	return clauses, nil
+}
+
+// WhyNotTree 基于 Expression 使用的JSON条件树返回规则不匹配的原因，叶子条件为JSON中的原始条件
+func WhyNotTree(exp map[string]interface{}, data map[string]interface{}) ([]FailedClause, error) {
+	expr, err := Expression(exp)
+	if err != nil {
+		return nil, err
+	}
+	clauses, err := WhyNot(expr, data)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make(map[string]ChildrenItem)
+	if err := collectItems(exp, items); err != nil {
+		return nil, err
+	}
+	for i := range clauses {
+		if item, ok := items[clauses[i].Expr]; ok {
+			clauses[i].ChildrenItem = item
+		}
+	}
+	return clauses, nil
+}
+
+// collectFailed 收集未满足期望的叶子条件，want 为节点期望的结果
+func collectFailed(n *ExplainNode, want bool, out *[]FailedClause) {
+	switch node := n.node.(type) {
+	case *ast.ParenExpr:
+		collectFailed(n.Children[0], want, out)
+		return
+	case *ast.UnaryExpr:
+		if node.Op == token.NOT {
+			collectFailed(n.Children[0], !want, out)
+			return
+		}
+	case *ast.BinaryExpr:
+		if node.Op == token.LAND || node.Op == token.LOR {
+			// 列出全部未满足期望结果的操作数：&& 不成立时为结果为false的条件，|| 不成立时为全部分支
+			var operands []*ExplainNode
+			flattenExplain(n, node.Op, &operands)
+			for _, operand := range operands {
+				if operand.Err != nil || operand.Value != want {
+					collectFailed(operand, want, out)
+				}
+			}
+			return
+		}
+	}
+
+	clause := FailedClause{Expr: n.Expr, Actual: n.Value, Negated: !want, Err: n.Err}
+	if bin, ok := n.node.(*ast.BinaryExpr); ok && len(n.Children) == 2 {
+		x, y := n.Children[0], n.Children[1]
+		clause.Field = exprString(unparen(bin.X))
+		clause.Op = opName(bin.Op)
+		clause.Value = y.result()
+		clause.Actual = x.result()
+	}
+	*out = append(*out, clause)
+}
+
+// flattenExplain 展开同一运算符连接的 &&、|| 链
+func flattenExplain(n *ExplainNode, op token.Token, out *[]*ExplainNode) {
+	for isParen(n.node) {
+		n = n.Children[0]
+	}
+	if bin, ok := n.node.(*ast.BinaryExpr); ok && bin.Op == op {
+		flattenExplain(n.Children[0], op, out)
+		flattenExplain(n.Children[1], op, out)
+		return
+	}
+	*out = append(*out, n)
+}
+
+// opName 表达式运算符对应的条件运算符标识，如 >= 对应 GE
+func opName(op token.Token) string {
+	for name, s := range itemOps {
+		if s == op.String() {
+			return name
+		}
+	}
+	return op.String()
+}
+
+// collectItems 收集JSON条件树中的叶子条件，以生成的表达式为键
+func collectItems(exp map[string]interface{}, out map[string]ChildrenItem) error {
+	childs, _ := exp["children"].([]interface{})
+	for _, item := range childs {
+		val, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := val["children"]; ok {
+			if err := collectItems(val, out); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ibyte, err := Jsoniter.Marshal(item)
+		if err != nil {
+			return err
+		}
+		var child ChildrenItem
+		if err := json.Unmarshal(ibyte, &child); err != nil {
+			return err
+		}
+		out[convert(child)] = child
+	}
+	return nil
+}